@@ -0,0 +1,150 @@
+package bankid
+
+// Starting the BankID app on the same device with the autostart token
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Links that start the BankID app
+const (
+	AppLinkBase       = "bankid:///"
+	UniversalLinkBase = "https://app.bankid.com/"
+)
+
+// RedirectNull - the redirect value for when the app should not open anything after the order,
+// the user is returned to the previous app by the OS or switches back manually
+const RedirectNull = "null"
+
+// Platform - the OS of the users device
+type Platform string
+
+const (
+	PlatformIOS     Platform = "ios"
+	PlatformAndroid Platform = "android"
+	PlatformDesktop Platform = "desktop"
+)
+
+// Browsers that need their own redirect on iOS, the rest are BrowserOther
+const (
+	BrowserSafari  = "safari"
+	BrowserChrome  = "chrome"
+	BrowserFirefox = "firefox"
+	BrowserEdge    = "edge"
+	BrowserOther   = "other"
+)
+
+// UserAgent - what a User-Agent header says about the users device
+type UserAgent struct {
+	Platform Platform
+	Browser  string // BrowserSafari, BrowserChrome, BrowserFirefox, BrowserEdge or BrowserOther
+	InApp    bool   // A web view inside another app, e.g Facebook or Instagram
+}
+
+var (
+	inAppAgent   = regexp.MustCompile(`FBAN|FBAV|FB_IAB|Instagram|LinkedInApp|Line/|Snapchat|; wv\)`)
+	iosAgent     = regexp.MustCompile(`iPhone|iPad|iPod`)
+	androidAgent = regexp.MustCompile(`Android`)
+)
+
+// ParseUserAgent - classifies a User-Agent header. iPads asking for the desktop site
+// look like macOS and are classified as desktop
+func ParseUserAgent(userAgent string) UserAgent {
+	ua := UserAgent{Platform: PlatformDesktop, Browser: BrowserOther, InApp: inAppAgent.MatchString(userAgent)}
+
+	switch {
+	case iosAgent.MatchString(userAgent):
+		ua.Platform = PlatformIOS
+		// Every iOS browser is WebKit, they identify themselves with their own token
+		switch {
+		case strings.Contains(userAgent, "CriOS/"):
+			ua.Browser = BrowserChrome
+		case strings.Contains(userAgent, "FxiOS/"):
+			ua.Browser = BrowserFirefox
+		case strings.Contains(userAgent, "EdgiOS/"):
+			ua.Browser = BrowserEdge
+		case strings.Contains(userAgent, "Safari/"):
+			ua.Browser = BrowserSafari
+		default:
+			// No Safari token, a web view
+			ua.InApp = true
+		}
+		return ua
+	case androidAgent.MatchString(userAgent):
+		ua.Platform = PlatformAndroid
+	}
+
+	switch {
+	case strings.Contains(userAgent, "Edg/"), strings.Contains(userAgent, "EdgA/"):
+		ua.Browser = BrowserEdge
+	case strings.Contains(userAgent, "Firefox/"):
+		ua.Browser = BrowserFirefox
+	case strings.Contains(userAgent, "Chrome/") && !strings.Contains(userAgent, "OPR/") && !strings.Contains(userAgent, "SamsungBrowser/"):
+		ua.Browser = BrowserChrome
+	case strings.Contains(userAgent, "Safari/") && !strings.Contains(userAgent, "Chrome/"):
+		ua.Browser = BrowserSafari
+	}
+	return ua
+}
+
+// Mobile - iOS and Android use universal links, desktops the bankid:/// scheme
+func (ua UserAgent) Mobile() bool {
+	return ua.Platform == PlatformIOS || ua.Platform == PlatformAndroid
+}
+
+// Redirect - where the BankID app should send the user after the order. Only iOS needs it,
+// Android and desktops return to the previous app on their own and get RedirectNull.
+// returnURL is the page the user started on, opened in the same browser
+func (ua UserAgent) Redirect(returnURL string) string {
+	if ua.Platform != PlatformIOS || ua.InApp || returnURL == "" {
+		return RedirectNull
+	}
+
+	switch ua.Browser {
+	case BrowserChrome:
+		// googlechrome://host/path for http, googlechromes:// for https
+		if u, err := url.Parse(returnURL); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			u.Scheme = strings.Replace(u.Scheme, "http", "googlechrome", 1)
+			return u.String()
+		}
+	case BrowserFirefox:
+		return "firefox://open-url?url=" + url.QueryEscape(returnURL)
+	case BrowserEdge:
+		return "microsoft-edge-" + returnURL
+	}
+	return returnURL
+}
+
+// AppLink - bankid:///?autostarttoken=...&redirect=...
+func AppLink(autoStartToken string, redirect string) string {
+	return AppLinkBase + launchQuery(autoStartToken, redirect)
+}
+
+// UniversalLink - https://app.bankid.com/?autostarttoken=...&redirect=...
+func UniversalLink(autoStartToken string, redirect string) string {
+	return UniversalLinkBase + launchQuery(autoStartToken, redirect)
+}
+
+// launchQuery - the redirect is escaped as a whole, query and all, except the literal null
+func launchQuery(autoStartToken string, redirect string) string {
+	query := "?autostarttoken=" + url.QueryEscape(autoStartToken)
+	if redirect == "" {
+		redirect = RedirectNull
+	}
+	if redirect != RedirectNull {
+		redirect = url.QueryEscape(redirect)
+	}
+	return query + "&redirect=" + redirect
+}
+
+// AutoStartLink - the link that starts the BankID app on the device of ua, with the redirect for it.
+// returnURL is the page to return to on iOS, see UserAgent.Redirect()
+func AutoStartLink(autoStartToken string, ua UserAgent, returnURL string) string {
+	redirect := ua.Redirect(returnURL)
+	if ua.Mobile() {
+		return UniversalLink(autoStartToken, redirect)
+	}
+	return AppLink(autoStartToken, redirect)
+}
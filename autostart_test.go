@@ -0,0 +1,55 @@
+package bankid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	uaSafariIOS   = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1"
+	uaChromeIOS   = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/123.0.6312.52 Mobile/15E148 Safari/604.1"
+	uaFirefoxIOS  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) FxiOS/124.0 Mobile/15E148 Safari/605.1.15"
+	uaFacebookIOS = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 [FBAN/FBIOS;FBAV/456.0.0.37.106]"
+	uaChromeAnd   = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/123.0.6312.99 Mobile Safari/537.36"
+	uaWebViewAnd  = "Mozilla/5.0 (Linux; Android 14; Pixel 8; wv) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/123.0.6312.99 Mobile Safari/537.36"
+	uaEdgeWin     = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/123.0.0.0 Safari/537.36 Edg/123.0.2420.81"
+	uaSafariMac   = "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_4_1) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	for userAgent, expected := range map[string]UserAgent{
+		uaSafariIOS:   {PlatformIOS, BrowserSafari, false},
+		uaChromeIOS:   {PlatformIOS, BrowserChrome, false},
+		uaFirefoxIOS:  {PlatformIOS, BrowserFirefox, false},
+		uaFacebookIOS: {PlatformIOS, BrowserOther, true},
+		uaChromeAnd:   {PlatformAndroid, BrowserChrome, false},
+		uaWebViewAnd:  {PlatformAndroid, BrowserChrome, true},
+		uaEdgeWin:     {PlatformDesktop, BrowserEdge, false},
+		uaSafariMac:   {PlatformDesktop, BrowserSafari, false},
+		"":            {PlatformDesktop, BrowserOther, false},
+	} {
+		assert.Equal(t, expected, ParseUserAgent(userAgent), userAgent)
+	}
+}
+
+func TestAutoStartLink(t *testing.T) {
+	token := "46b6e9a4-9b7b-4a8e-9a3d-7a2f3c1b0e11"
+	returnURL := "https://example.com/login?state=a b&lang=sv"
+
+	for userAgent, expected := range map[string]string{
+		uaSafariIOS:   "https://app.bankid.com/?autostarttoken=" + token + "&redirect=https%3A%2F%2Fexample.com%2Flogin%3Fstate%3Da+b%26lang%3Dsv",
+		uaChromeIOS:   "https://app.bankid.com/?autostarttoken=" + token + "&redirect=googlechromes%3A%2F%2Fexample.com%2Flogin%3Fstate%3Da+b%26lang%3Dsv",
+		uaFirefoxIOS:  "https://app.bankid.com/?autostarttoken=" + token + "&redirect=firefox%3A%2F%2Fopen-url%3Furl%3Dhttps%253A%252F%252Fexample.com%252Flogin%253Fstate%253Da%2Bb%2526lang%253Dsv",
+		uaFacebookIOS: "https://app.bankid.com/?autostarttoken=" + token + "&redirect=null",
+		uaChromeAnd:   "https://app.bankid.com/?autostarttoken=" + token + "&redirect=null",
+		uaEdgeWin:     "bankid:///?autostarttoken=" + token + "&redirect=null",
+	} {
+		assert.Equal(t, expected, AutoStartLink(token, ParseUserAgent(userAgent), returnURL), userAgent)
+	}
+
+	// Nowhere to return to
+	assert.Equal(t, "https://app.bankid.com/?autostarttoken="+token+"&redirect=null", AutoStartLink(token, ParseUserAgent(uaSafariIOS), ""))
+	assert.Equal(t, "bankid:///?autostarttoken="+token+"&redirect=null", AppLink(token, ""))
+	assert.Equal(t, "microsoft-edge-https://example.com/", UserAgent{Platform: PlatformIOS, Browser: BrowserEdge}.Redirect("https://example.com/"))
+}
@@ -1,25 +1,24 @@
 package bankid
 
 import (
+	"context"
 	"crypto/tls"
-	"crypto/x509"
 	"encoding/json"
-	"encoding/pem"
 	"fmt"
-	"io/ioutil"
 	"net"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
-
-	pkcs12 "software.sslmate.com/src/go-pkcs12"
 )
 
 // API Constants
 const (
 	ProductionBaseURL string = "https://appapi2.bankid.com"
 	TestBaseURL       string = "https://appapi2.test.bankid.com"
-	APIVersion        string = "/rp/v5"
+	APIVersion        string = "/rp/v5" // Default version, kept for v5 callers
+	APIVersionV5      string = "/rp/v5"
+	APIVersionV6      string = "/rp/v6.0"
 	AuthEndpoint      string = "/auth"
 	SignEndpoint      string = "/sign"
 	CollectEndpoint   string = "/collect"
@@ -30,99 +29,92 @@ const (
 // Helps setup requests to the BankID API
 type Environmenter interface {
 	NewClient() *http.Client
-	NewRequest(endpoint string, body interface{}) (*http.Request, error)
+	NewRequest(ctx context.Context, endpoint string, body interface{}) (*http.Request, error)
+}
+
+// APIVersioner - implemented by environments that speak another
+// RP API version than the default APIVersion
+type APIVersioner interface {
+	APIVersion() string
 }
 
 type environment struct {
 	baseURL      string
+	apiVersion   string
 	clientConfig *tls.Config
+	timeout      time.Duration
+	dialTimeout  time.Duration
+	proxy        func(*http.Request) (*url.URL, error)
+	httpClient   *http.Client // Replaces the one from NewClient() when set
 }
 
-func NewEnvironmentP12(baseURL string, caPath string, rpP12Path string) (Environmenter, error) {
-	ca, err := ioutil.ReadFile(caPath)
-	if err != nil {
-		return nil, fmt.Errorf("could not load CA Certificate: %s", err.Error())
-	}
-
-	p12bytres, err :=  ioutil.ReadFile(rpP12Path)
-	if err != nil {
-		return nil, fmt.Errorf("could not load p12 certificate: %s", err.Error())
+// UseAPIVersion - returns a copy of an environment created by this package
+// that speaks the provided RP API version, APIVersionV5 or APIVersionV6
+func UseAPIVersion(env Environmenter, version string) (Environmenter, error) {
+	if version != APIVersionV5 && version != APIVersionV6 {
+		return nil, fmt.Errorf("%s is not a supported API version", version)
 	}
 
-	blocks, err := pkcs12.ToPEM(p12bytres, "qwerty123")
-	if err != nil {
-		return nil, fmt.Errorf("could not converto to pem: %s", err.Error())
+	e, ok := env.(*environment)
+	if !ok {
+		return nil, fmt.Errorf("can only change API version of environments created by this package")
 	}
 
-	var pemData []byte
-	for _, b := range blocks {
-		pemData = append(pemData, pem.EncodeToMemory(b)...)
-	}
+	versioned := *e
+	versioned.apiVersion = version
+	return &versioned, nil
+}
 
-	// then use PEM data for tls to construct tls certificate:
-	rpCert, err := tls.X509KeyPair(pemData, pemData)
-	if err != nil {
-		return nil, fmt.Errorf("could not load RP Keypair: %s", err.Error())
+// apiVersion - the RP API version spoken by env, APIVersion unless told otherwise
+func apiVersion(env Environmenter) string {
+	if v, ok := env.(APIVersioner); ok && v.APIVersion() != "" {
+		return v.APIVersion()
 	}
+	return APIVersion
+}
 
-	caPool := x509.NewCertPool()
+// NewEnvironmentP12 - same as NewEnvironment() but with the RP certificate, key
+// and intermediate chain in a password protected PKCS#12 (.p12/.pfx) file
+func NewEnvironmentP12(baseURL string, caPath string, rpP12Path string, password string) (Environmenter, error) {
+	return New(WithBaseURL(baseURL), WithCAFile(caPath), WithP12File(rpP12Path, password))
+}
 
-	if caPool.AppendCertsFromPEM(ca) == false {
-		return nil, fmt.Errorf("could not append CA Certificate to pool. Invalid certificate?")
+// NewEnvironmentP12Func - same as NewEnvironmentP12() but asks password for the password,
+// e.g from a secret manager
+func NewEnvironmentP12Func(baseURL string, caPath string, rpP12Path string, password PasswordFunc) (Environmenter, error) {
+	secret, err := password()
+	if err != nil {
+		return nil, fmt.Errorf("could not get p12 password: %s", err.Error())
 	}
 
-	clientCfg := tls.Config{
-		Certificates: []tls.Certificate{rpCert},
-		ClientCAs:    caPool,
-		RootCAs:      caPool,
-		// InsecureSkipVerify: true, // For some reason is BankID not using a proper domain certificate
-	}
-	return &environment{
-		baseURL:      baseURL,
-		clientConfig: &clientCfg,
-	}, nil
+	return NewEnvironmentP12(baseURL, caPath, rpP12Path, secret)
 }
 
-
 // NewEnvironment - sets up the certificates and URLs needed to identify ourselves with the BankID service
 func NewEnvironment(baseURL string, caPath string, rpCertPath string, rpKeyPath string) (Environmenter, error) {
-	ca, err := ioutil.ReadFile(caPath)
-	if err != nil {
-		return nil, fmt.Errorf("could not load CA Certificate: %s", err.Error())
-	}
-
-	rpCert, err := tls.LoadX509KeyPair(rpCertPath, rpKeyPath)
-	if err != nil {
-		return nil, fmt.Errorf("could not load RP Keypair: %s", err.Error())
-	}
-
-	caPool := x509.NewCertPool()
+	return New(WithBaseURL(baseURL), WithCAFile(caPath), WithKeyPairFiles(rpCertPath, rpKeyPath))
+}
 
-	if caPool.AppendCertsFromPEM(ca) == false {
-		return nil, fmt.Errorf("could not append CA Certificate to pool. Invalid certificate?")
-	}
+// APIVersion - the RP API version this environment speaks
+func (e *environment) APIVersion() string {
+	return e.apiVersion
+}
 
-	clientCfg := tls.Config{
-		Certificates: []tls.Certificate{rpCert},
-		ClientCAs:    caPool,
-		RootCAs:      caPool,
-		// InsecureSkipVerify: true, // For some reason is BankID not using a proper domain certificate
-	}
-	return &environment{
-		baseURL:      baseURL,
-		clientConfig: &clientCfg,
-	}, nil
+// TLSConfig - a copy of the mutual TLS configuration, see Client.
+// nil when the environment uses a http.Client from WithHTTPClient()
+func (e *environment) TLSConfig() *tls.Config {
+	return e.clientConfig.Clone()
 }
 
-// NewRequest - helper function to bake a request
-func (e *environment) NewRequest(endpoint string, body interface{}) (*http.Request, error) {
+// NewRequest - helper function to bake a request bound to ctx
+func (e *environment) NewRequest(ctx context.Context, endpoint string, body interface{}) (*http.Request, error) {
 	requestBody, err := json.Marshal(body)
 	if err != nil {
 		return nil, err
 	}
 
 	bodyReader := strings.NewReader(string(requestBody))
-	req, err := http.NewRequest("POST", e.baseURL+APIVersion+endpoint, bodyReader)
+	req, err := http.NewRequestWithContext(ctx, "POST", e.baseURL+e.apiVersion+endpoint, bodyReader)
 	if err != nil {
 		return nil, err
 	}
@@ -133,16 +125,21 @@ func (e *environment) NewRequest(endpoint string, body interface{}) (*http.Reque
 
 // NewRequest - helper function to bake a new http.Client with our TLS Confnig
 func (e *environment) NewClient() *http.Client {
+	if e.httpClient != nil {
+		return e.httpClient
+	}
+
 	return &http.Client{
 		Transport: &http.Transport{
-			Dial: (&net.Dialer{
-				Timeout:   5 * time.Second,
+			Proxy: e.proxy,
+			DialContext: (&net.Dialer{
+				Timeout:   e.dialTimeout,
 				KeepAlive: 30 * time.Second,
-			}).Dial,
+			}).DialContext,
 			TLSClientConfig:     e.clientConfig,
 			TLSHandshakeTimeout: 5 * time.Second,
 			IdleConnTimeout:     90 * time.Second,
 		},
-		Timeout: 10 * time.Second,
+		Timeout: e.timeout,
 	}
 }
@@ -1,6 +1,7 @@
 package bankid
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -33,11 +34,11 @@ func TestRequestsBad(t *testing.T) {
 
 	// Bad body
 	var invalidBodyType chan int
-	_, err = env.NewRequest("endpoint", invalidBodyType)
+	_, err = env.NewRequest(context.Background(), "endpoint", invalidBodyType)
 	assert.NotNil(t, err)
 
 	// Bad schema
-	_, err = env.NewRequest("endpoint", "")
+	_, err = env.NewRequest(context.Background(), "endpoint", "")
 	assert.NotNil(t, err)
 }
 
@@ -47,7 +48,7 @@ func TestRequestsOK(t *testing.T) {
 	assert.NotNil(t, env)
 
 	// All A OK
-	req, err := env.NewRequest("endpoint", "")
+	req, err := env.NewRequest(context.Background(), "endpoint", "")
 	assert.Nil(t, err)
 	assert.NotNil(t, req)
 
@@ -55,3 +56,27 @@ func TestRequestsOK(t *testing.T) {
 	client := env.NewClient()
 	assert.NotNil(t, client)
 }
+
+func TestUseAPIVersion(t *testing.T) {
+	env, err := NewEnvironment(ProductionBaseURL, "./CA/test.crt", "./rp/bankid_rp_test.crt", "./rp/bankid_rp_test.key")
+	assert.Nil(t, err)
+	assert.Equal(t, APIVersionV5, apiVersion(env))
+
+	// Unknown versions
+	_, err = UseAPIVersion(env, "/rp/v4")
+	assert.NotNil(t, err)
+
+	// Foreign environments
+	_, err = UseAPIVersion(&testEnv{}, APIVersionV6)
+	assert.NotNil(t, err)
+	assert.Equal(t, APIVersion, apiVersion(&testEnv{}))
+
+	v6, err := UseAPIVersion(env, APIVersionV6)
+	assert.Nil(t, err)
+	assert.Equal(t, APIVersionV6, apiVersion(v6))
+	assert.Equal(t, APIVersionV5, apiVersion(env)) // Original untouched
+
+	req, err := v6.NewRequest(context.Background(), AuthEndpoint, "")
+	assert.Nil(t, err)
+	assert.Equal(t, ProductionBaseURL+"/rp/v6.0/auth", req.URL.String())
+}
@@ -0,0 +1,123 @@
+package bankidtest
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"time"
+
+	"github.com/onlyangel/bankid"
+	"github.com/onlyangel/bankid/internal/signtest"
+	"golang.org/x/crypto/ocsp"
+)
+
+// certificates - a throwaway CA with a server and a RP client certificate,
+// and a BankID root and bank CA for the users certificates
+type certificates struct {
+	caPEM  []byte
+	caPool *x509.CertPool
+	server tls.Certificate
+	client tls.Certificate
+
+	bankID       *signtest.Chain
+	responder    *x509.Certificate // OCSP responder of the bank CA
+	responderKey crypto.Signer
+}
+
+func newCertificates() (*certificates, error) {
+	caKey, err := signtest.NewKey()
+	if err != nil {
+		return nil, err
+	}
+	ca, err := signtest.Issue(signtest.Template("bankidtest Root CA v1", true), caKey, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	serverTemplate := signtest.Template("bankidtest server", false)
+	serverTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	serverTemplate.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	serverTemplate.DNSNames = []string{"localhost"}
+	server, err := issue(serverTemplate, ca, caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	clientTemplate := signtest.Template("bankidtest RP", false)
+	clientTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	client, err := issue(clientTemplate, ca, caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	caPool := x509.NewCertPool()
+	caPool.AddCert(ca)
+
+	bankID, err := signtest.NewChain("bankidtest")
+	if err != nil {
+		return nil, err
+	}
+	responder, responderKey, err := bankID.Responder(x509.ExtKeyUsageOCSPSigning)
+	if err != nil {
+		return nil, err
+	}
+
+	return &certificates{
+		caPEM:        pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}),
+		caPool:       caPool,
+		server:       server,
+		client:       client,
+		bankID:       bankID,
+		responder:    responder,
+		responderKey: responderKey,
+	}, nil
+}
+
+// userCertificate - a users BankID, issued by the bank CA. The chain is user, bank and root
+func (c *certificates) userCertificate(user *bankid.User) ([]*x509.Certificate, crypto.Signer, error) {
+	key, err := signtest.NewKey()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	t := signtest.Template(user.Name, false)
+	t.Subject.SerialNumber = user.PersonalNumber
+	t.Subject.Country = []string{"SE"}
+	chain, err := c.bankID.User(t, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return chain, key, nil
+}
+
+// issue - a TLS certificate with a new key, issued by the throwaway CA
+func issue(template *x509.Certificate, ca *x509.Certificate, caKey crypto.Signer) (tls.Certificate, error) {
+	key, err := signtest.NewKey()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	leaf, err := signtest.Issue(template, key, ca, caKey)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{leaf.Raw},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
+
+// ocspResponse - a good status for a users certificate, by the bank CAs responder
+func (c *certificates) ocspResponse(cert *x509.Certificate, now time.Time) ([]byte, error) {
+	return ocsp.CreateResponse(c.bankID.Bank, c.responder, ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: cert.SerialNumber,
+		ThisUpdate:   now,
+		NextUpdate:   now.Add(12 * time.Hour),
+		Certificate:  c.responder,
+	}, c.responderKey)
+}
@@ -0,0 +1,260 @@
+package bankidtest
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/onlyangel/bankid"
+	"github.com/onlyangel/bankid/internal/signtest"
+	"github.com/onlyangel/bankid/signature"
+)
+
+// The RP in the srvInfo of signatures
+const (
+	rpName        = "cn=bankidtest RP,name=bankidtest,serialNumber=5566778899,c=SE"
+	rpDisplayName = "bankidtest"
+)
+
+// ErrNoSuchOrder - the orderRef is unknown, cancelled or forgotten
+var ErrNoSuchOrder = errors.New("no such order")
+
+// ErrInvalidTransition - the user can't do that in the current state of the order
+var ErrInvalidTransition = errors.New("invalid transition")
+
+// Order - the fake BankID servers view of an order
+type Order struct {
+	OrderRef       string
+	Endpoint       string // bankid.AuthEndpoint, bankid.SignEndpoint, "/phone/auth" or "/phone/sign"
+	APIVersion     string
+	PersonalNumber string
+	EndUserIP      string
+	Request        bankid.Request // As received, user data still base64 encoded
+	Response       bankid.Response
+	Status         string
+	HintCode       string
+	Completion     *bankid.Completion
+	Created        time.Time
+	Finished       time.Time
+}
+
+func (o *Order) pending() bool {
+	return o.Status == bankid.OrderPending
+}
+
+// transition - move a pending order on if its hint code is one of from
+func (s *Server) transition(orderRef string, status string, hintCode string, from ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[orderRef]
+	if !ok {
+		return ErrNoSuchOrder
+	}
+	s.expire(order)
+
+	if !order.pending() {
+		return fmt.Errorf("%w: order is %s", ErrInvalidTransition, order.Status)
+	}
+
+	allowed := len(from) == 0
+	for _, hint := range from {
+		allowed = allowed || order.HintCode == hint
+	}
+	if !allowed {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, order.HintCode, hintCode)
+	}
+
+	order.Status = status
+	order.HintCode = hintCode
+	if status != bankid.OrderPending {
+		s.finish(order)
+	}
+	return nil
+}
+
+// NoClient - the app was not started, only for orders with a personal number
+func (s *Server) NoClient(orderRef string) error {
+	return s.transition(orderRef, bankid.OrderPending, bankid.PendNoClient, bankid.PendOutstandingTransaction)
+}
+
+// StartApp - the BankID app was started and is looking for a BankID
+func (s *Server) StartApp(orderRef string) error {
+	return s.transition(orderRef, bankid.OrderPending, bankid.PendStarted,
+		bankid.PendOutstandingTransaction, bankid.PendNoClient)
+}
+
+// Scan - the user scanned the QR code or picked the order in the app, and is asked for the security code
+func (s *Server) Scan(orderRef string) error {
+	return s.transition(orderRef, bankid.OrderPending, bankid.PendUserSign,
+		bankid.PendOutstandingTransaction, bankid.PendNoClient, bankid.PendStarted)
+}
+
+// UserCancel - the user pressed cancel in the app
+func (s *Server) UserCancel(orderRef string) error {
+	return s.transition(orderRef, bankid.OrderFailed, bankid.FailUserCancel)
+}
+
+// Timeout - the order expires right away, as if the user never finished
+func (s *Server) Timeout(orderRef string) error {
+	return s.transition(orderRef, bankid.OrderFailed, bankid.FailExpiredTransaction)
+}
+
+// FailStart - the app could not be started
+func (s *Server) FailStart(orderRef string) error {
+	return s.transition(orderRef, bankid.OrderFailed, bankid.FailStartFailed,
+		bankid.PendOutstandingTransaction, bankid.PendNoClient)
+}
+
+// CertificateError - the users BankID is blocked or too old
+func (s *Server) CertificateError(orderRef string) error {
+	return s.transition(orderRef, bankid.OrderFailed, bankid.FailCertificateErr,
+		bankid.PendStarted, bankid.PendUserSign)
+}
+
+// Sign - the user entered the security code, the order is complete.
+// The user is the one registered for the personal number with SetUser(), or a default one
+func (s *Server) Sign(orderRef string) error {
+	return s.SignAs(orderRef, nil)
+}
+
+// SignAs - same as Sign() but as the provided user
+func (s *Server) SignAs(orderRef string, user *bankid.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[orderRef]
+	if !ok {
+		return ErrNoSuchOrder
+	}
+	s.expire(order)
+
+	if !order.pending() || order.HintCode != bankid.PendUserSign {
+		return fmt.Errorf("%w: %s/%s -> %s", ErrInvalidTransition, order.Status, order.HintCode, bankid.OrderComplete)
+	}
+
+	if user == nil {
+		user = s.userFor(order.PersonalNumber)
+	}
+	if order.PersonalNumber != "" && user.PersonalNumber != order.PersonalNumber {
+		return fmt.Errorf("%w: order is for %s, not %s", ErrInvalidTransition, order.PersonalNumber, user.PersonalNumber)
+	}
+
+	s.complete(order, user)
+	return nil
+}
+
+// complete - finish the order as signed by user. Call with s.mu held
+func (s *Server) complete(order *Order, user *bankid.User) {
+	now := s.now()
+	sig, ocspResponse := s.signature(order, user)
+	completion := &bankid.Completion{
+		User:         *user,
+		Device:       bankid.Device{IPAddress: order.EndUserIP},
+		Signature:    sig,
+		OCSPResponse: ocspResponse,
+	}
+	if order.APIVersion == bankid.APIVersionV6 {
+		completion.Device.UHI = "bankidtest-uhi"
+		completion.BankIDIssueDate = now.AddDate(-1, 0, 0).Format("2006-01-02")
+		completion.StepUp = &bankid.StepUp{Mrtd: order.Request.Requirement != nil && order.Request.Requirement.Mrtd}
+		if order.Request.ReturnRisk {
+			completion.Risk = "low"
+		}
+	} else {
+		completion.Cert = bankid.Cert{
+			NotBefore: fmt.Sprint(now.AddDate(-1, 0, 0).UnixNano() / int64(time.Millisecond)),
+			NotAfter:  fmt.Sprint(now.AddDate(1, 0, 0).UnixNano() / int64(time.Millisecond)),
+		}
+	}
+
+	order.Status = bankid.OrderComplete
+	order.HintCode = ""
+	order.Completion = completion
+	s.finish(order)
+}
+
+// signature - the base64 XML signature by the users certificate over what the order asked for,
+// and the OCSP response for the certificate. Verifies against BankIDRoots()
+func (s *Server) signature(order *Order, user *bankid.User) (string, string) {
+	chain, key, err := s.certs.userCertificate(user)
+	if err != nil {
+		panic(fmt.Sprintf("bankidtest: could not issue user certificate: %s", err.Error()))
+	}
+
+	visible, _ := base64.StdEncoding.DecodeString(order.Request.UserVisibleData)
+	nonVisible, _ := base64.StdEncoding.DecodeString(order.Request.UserNonVisibleData)
+	nonce := make([]byte, 20)
+	rand.Read(nonce)
+
+	data := &signature.Data{
+		VisibleData:    visible,
+		NonVisibleData: nonVisible,
+		Nonce:          base64.StdEncoding.EncodeToString(nonce),
+		RPName:         rpName,
+		RPDisplayName:  rpDisplayName,
+		Function:       signature.FunctionIdentification,
+	}
+	if order.Endpoint == bankid.SignEndpoint || order.Endpoint == phoneSignEndpoint {
+		data.Function = signature.FunctionSigning
+	}
+
+	xml, err := signtest.Create(data, chain, key)
+	if err != nil {
+		panic(fmt.Sprintf("bankidtest: could not create signature: %s", err.Error()))
+	}
+
+	ocspResponse, err := s.certs.ocspResponse(chain[0], s.now())
+	if err != nil {
+		panic(fmt.Sprintf("bankidtest: could not create OCSP response: %s", err.Error()))
+	}
+	return base64.StdEncoding.EncodeToString(xml), base64.StdEncoding.EncodeToString(ocspResponse)
+}
+
+// finish - call with s.mu held
+func (s *Server) finish(order *Order) {
+	order.Finished = s.now()
+	if s.active[order.PersonalNumber] == order.OrderRef {
+		delete(s.active, order.PersonalNumber)
+	}
+}
+
+// expire - pending orders older than the TTL fail, finished orders are forgotten
+// after the retention time. Call with s.mu held
+func (s *Server) expire(order *Order) {
+	now := s.now()
+	if order.pending() && now.Sub(order.Created) >= s.cfg.OrderTTL {
+		order.Status = bankid.OrderFailed
+		order.HintCode = bankid.FailExpiredTransaction
+		s.finish(order)
+	}
+
+	if !order.pending() && now.Sub(order.Finished) >= s.cfg.Retention {
+		delete(s.orders, order.OrderRef)
+	}
+}
+
+func (s *Server) userFor(personalNumber string) *bankid.User {
+	if user, ok := s.users[personalNumber]; ok {
+		return &user
+	}
+	if personalNumber == "" {
+		personalNumber = DefaultPersonalNumber
+	}
+	return &bankid.User{
+		PersonalNumber: personalNumber,
+		Name:           "Test Testsson",
+		GivenName:      "Test",
+		Surname:        "Testsson",
+	}
+}
+
+func uuid() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40 // Version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // Variant 10
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
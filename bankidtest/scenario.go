@@ -0,0 +1,283 @@
+package bankidtest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/onlyangel/bankid"
+)
+
+// MalformedJSON - body of Malformed() steps
+const MalformedJSON = `{"orderRef": "131daac9-16c6-4618-beb0-`
+
+// step - one scripted answer
+type step struct {
+	status     string // Collect only, move the order to status/hintCode before answering
+	hintCode   string
+	httpStatus int // Answer with this instead of asking the server
+	errorCode  string
+	details    string
+	mediaType  string // Raw answers only
+	body       string
+	latency    time.Duration
+}
+
+// Scenario - a script of answers for one endpoint, e.g to reproduce a production incident:
+//
+//	bankidtest.NewScenario().
+//		Pending(bankid.PendOutstandingTransaction).Times(3).
+//		Pending(bankid.PendStarted).
+//		Pending(bankid.PendUserSign).
+//		Fail(bankid.FailUserCancel)
+//
+// Each request to the endpoint uses up one step. When the script runs out the server
+// answers as usual, based on the state of the order.
+type Scenario struct {
+	steps []step
+}
+
+// NewScenario - an empty scenario
+func NewScenario() *Scenario {
+	return &Scenario{}
+}
+
+func (s *Scenario) add(st step) *Scenario {
+	s.steps = append(s.steps, st)
+	return s
+}
+
+// Pending - collect: the order is pending with hintCode
+func (s *Scenario) Pending(hintCode string) *Scenario {
+	return s.add(step{status: bankid.OrderPending, hintCode: hintCode})
+}
+
+// Fail - collect: the order failed with hintCode
+func (s *Scenario) Fail(hintCode string) *Scenario {
+	return s.add(step{status: bankid.OrderFailed, hintCode: hintCode})
+}
+
+// Complete - collect: the order is complete, signed by the user for its personal number
+func (s *Scenario) Complete() *Scenario {
+	return s.add(step{status: bankid.OrderComplete})
+}
+
+// Error - an ErrorResponse with the HTTP status and error code, e.g 503 and bankid.CodeMaintenance
+func (s *Scenario) Error(httpStatus int, errorCode string) *Scenario {
+	return s.add(step{httpStatus: httpStatus, errorCode: errorCode, details: "Scripted " + errorCode})
+}
+
+// Raw - any body, e.g an HTML error page from a proxy
+func (s *Scenario) Raw(httpStatus int, mediaType string, body string) *Scenario {
+	return s.add(step{httpStatus: httpStatus, mediaType: mediaType, body: body})
+}
+
+// Malformed - a 200 OK with truncated JSON
+func (s *Scenario) Malformed() *Scenario {
+	return s.Raw(http.StatusOK, "application/json", MalformedJSON)
+}
+
+// Pass - let the server answer as usual, useful with Delay()
+func (s *Scenario) Pass() *Scenario {
+	return s.add(step{})
+}
+
+// Times - repeat the previous step so it's used n times in total
+func (s *Scenario) Times(n int) *Scenario {
+	if len(s.steps) == 0 {
+		return s
+	}
+	last := s.steps[len(s.steps)-1]
+	for i := 1; i < n; i++ {
+		s.steps = append(s.steps, last)
+	}
+	return s
+}
+
+// Delay - the previous step answers after d, or when the client gives up
+func (s *Scenario) Delay(d time.Duration) *Scenario {
+	if len(s.steps) > 0 {
+		s.steps[len(s.steps)-1].latency = d
+	}
+	return s
+}
+
+// Len - number of steps left
+func (s *Scenario) Len() int {
+	return len(s.steps)
+}
+
+// ParseScenario - a Scenario from a comma separated script, e.g
+//
+//	outstandingTransaction x3, started, userSign, then failed/userCancel
+//	503 maintenance x2, complete
+//	pass delay 2s, malformed
+//
+// Steps are a known pending hint code, pending/<hintCode>, failed/<hintCode>,
+// complete, <HTTP status> <error code>, malformed or pass. They may be followed by
+// x<count> and delay <duration>, and preceded by "then". Other hint codes, e.g for
+// hints newer than this package, need the pending/ or failed/ prefix so typos are caught.
+func ParseScenario(script string) (*Scenario, error) {
+	scenario := NewScenario()
+
+	for i, item := range strings.Split(script, ",") {
+		fields := strings.Fields(item)
+		if len(fields) > 0 && fields[0] == "then" {
+			fields = fields[1:]
+		}
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("step %d: empty", i+1)
+		}
+
+		rest, err := parseStep(scenario, fields)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %s", i+1, err.Error())
+		}
+
+		for len(rest) > 0 {
+			switch {
+			case rest[0] == "delay" && len(rest) > 1:
+				d, err := time.ParseDuration(rest[1])
+				if err != nil {
+					return nil, fmt.Errorf("step %d: %s", i+1, err.Error())
+				}
+				scenario.Delay(d)
+				rest = rest[2:]
+			case strings.HasPrefix(rest[0], "x"):
+				n, err := strconv.Atoi(rest[0][1:])
+				if err != nil || n < 1 {
+					return nil, fmt.Errorf("step %d: invalid count %q", i+1, rest[0])
+				}
+				scenario.Times(n)
+				rest = rest[1:]
+			default:
+				return nil, fmt.Errorf("step %d: unexpected %q", i+1, rest[0])
+			}
+		}
+	}
+
+	return scenario, nil
+}
+
+// pendingHints - the hint codes that may be used without pending/
+var pendingHints = map[string]bool{
+	bankid.PendOutstandingTransaction: true,
+	bankid.PendNoClient:               true,
+	bankid.PendStarted:                true,
+	bankid.PendUserSign:               true,
+}
+
+// parseStep - adds the step in the leading fields, returns the modifiers after it
+func parseStep(scenario *Scenario, fields []string) ([]string, error) {
+	if status, err := strconv.Atoi(fields[0]); err == nil {
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("missing error code after %d", status)
+		}
+		scenario.Error(status, fields[1])
+		return fields[2:], nil
+	}
+
+	status, hintCode := "", ""
+	if i := strings.Index(fields[0], "/"); i >= 0 {
+		status, hintCode = fields[0][:i], fields[0][i+1:]
+	} else if pendingHints[fields[0]] {
+		status, hintCode = bankid.OrderPending, fields[0]
+	}
+
+	switch {
+	case fields[0] == bankid.OrderComplete:
+		scenario.Complete()
+	case fields[0] == "malformed":
+		scenario.Malformed()
+	case fields[0] == "pass":
+		scenario.Pass()
+	case status == bankid.OrderPending && hintCode != "":
+		scenario.Pending(hintCode)
+	case status == bankid.OrderFailed && hintCode != "":
+		scenario.Fail(hintCode)
+	default:
+		return nil, fmt.Errorf("unknown step %q", fields[0])
+	}
+	return fields[1:], nil
+}
+
+// Script - queue the scenario for endpoint, e.g bankid.CollectEndpoint, after any steps
+// already queued. Order states (Pending, Fail, Complete) are for collect only
+func (s *Server) Script(endpoint string, scenario *Scenario) error {
+	if endpoint != bankid.CollectEndpoint {
+		for _, st := range scenario.steps {
+			if st.status != "" {
+				return fmt.Errorf("%s: only collect can be scripted with order states", endpoint)
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts[endpoint] = append(s.scripts[endpoint], scenario.steps...)
+	return nil
+}
+
+// nextStep - the next scripted step for endpoint, if any
+func (s *Server) nextStep(endpoint string) (step, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	steps := s.scripts[endpoint]
+	if len(steps) == 0 {
+		return step{}, false
+	}
+	s.scripts[endpoint] = steps[1:]
+	return steps[0], true
+}
+
+// play - answers with the step, false if the server should answer as usual
+func (s *Server) play(ctx context.Context, w http.ResponseWriter, request *bankid.Request, st step) bool {
+	if st.latency > 0 {
+		select {
+		case <-time.After(st.latency):
+		case <-ctx.Done():
+			return true
+		}
+	}
+
+	switch {
+	case st.httpStatus != 0 && st.errorCode != "":
+		writeError(w, st.httpStatus, st.errorCode, st.details)
+		return true
+	case st.httpStatus != 0:
+		w.Header().Set("Content-Type", st.mediaType)
+		w.WriteHeader(st.httpStatus)
+		w.Write([]byte(st.body))
+		return true
+	case st.status != "":
+		s.force(request.OrderRef, st)
+	}
+	return false
+}
+
+// force - move the order to the state of the step, skipping the usual transition checks
+func (s *Server) force(orderRef string, st step) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[orderRef]
+	if !ok {
+		return
+	}
+
+	switch st.status {
+	case bankid.OrderComplete:
+		s.complete(order, s.userFor(order.PersonalNumber))
+	case bankid.OrderFailed:
+		order.Status = st.status
+		order.HintCode = st.hintCode
+		s.finish(order)
+	default:
+		order.Status = st.status
+		order.HintCode = st.hintCode
+	}
+}
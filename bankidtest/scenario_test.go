@@ -0,0 +1,211 @@
+package bankidtest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onlyangel/bankid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseScenario(t *testing.T) {
+	for script, expected := range map[string]*Scenario{
+		"outstandingTransaction x3, started, userSign, then failed/userCancel": NewScenario().
+			Pending(bankid.PendOutstandingTransaction).Times(3).
+			Pending(bankid.PendStarted).
+			Pending(bankid.PendUserSign).
+			Fail(bankid.FailUserCancel),
+		"503 maintenance x2, complete": NewScenario().
+			Error(http.StatusServiceUnavailable, bankid.CodeMaintenance).Times(2).
+			Complete(),
+		"pass delay 2s, pending/noClient x2 delay 10ms, malformed": NewScenario().
+			Pass().Delay(2 * time.Second).
+			Pending(bankid.PendNoClient).Times(2).Delay(10 * time.Millisecond).
+			Malformed(),
+		"pending/userMrtd, failed/somethingNew": NewScenario().
+			Pending("userMrtd").
+			Fail("somethingNew"),
+	} {
+		scenario, err := ParseScenario(script)
+		assert.NoError(t, err, script)
+		assert.Equal(t, expected, scenario, script)
+	}
+
+	for _, script := range []string{
+		"",
+		"started,,userSign",
+		"503",
+		"failed/",
+		"started x0",
+		"started delay soon",
+		"started please",
+		"complet",           // Typo, not a hint code
+		"failed",            // Needs a hint code
+		"userCancel",        // A failed hint code
+		"started, usersign", // Hint codes are case sensitive
+		"pending/",
+	} {
+		_, err := ParseScenario(script)
+		assert.Error(t, err, script)
+	}
+}
+
+func TestScriptedCollect(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	env := newEnv(t, server, bankid.APIVersionV5)
+
+	scenario, _ := ParseScenario("outstandingTransaction x3, noClient, started, userSign, then failed/userCancel")
+	assert.NoError(t, server.Script(bankid.CollectEndpoint, scenario))
+
+	rsp, err := bankid.Auth(env, "198001010001", "127.0.0.1")
+	assert.NoError(t, err)
+
+	var seen []string
+	for i := 0; i < 7; i++ {
+		collect, err := bankid.Collect(env, rsp.OrderRef)
+		assert.NoError(t, err)
+		seen = append(seen, collect.Status+"/"+collect.HintCode)
+	}
+	assert.Equal(t, []string{
+		"pending/outstandingTransaction",
+		"pending/outstandingTransaction",
+		"pending/outstandingTransaction",
+		"pending/noClient",
+		"pending/started",
+		"pending/userSign",
+		"failed/userCancel",
+	}, seen)
+
+	// The order really failed, the script is not only a recording
+	order, _ := server.Order(rsp.OrderRef)
+	assert.Equal(t, bankid.OrderFailed, order.Status)
+	_, err = bankid.Auth(env, "198001010001", "127.0.0.1")
+	assert.NoError(t, err)
+}
+
+func TestScriptedMaintenance(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	env := newEnv(t, server, bankid.APIVersionV5)
+
+	scenario, _ := ParseScenario("503 maintenance x2, complete")
+	assert.NoError(t, server.Script(bankid.CollectEndpoint, scenario))
+
+	rsp, _ := bankid.Auth(env, "198001010001", "127.0.0.1")
+
+	for i := 0; i < 2; i++ {
+		_, err := bankid.Collect(env, rsp.OrderRef)
+		assert.True(t, errors.Is(err, bankid.ErrMaintenance))
+
+		var errRsp bankid.ErrorResponse
+		if assert.True(t, errors.As(err, &errRsp)) {
+			assert.Equal(t, http.StatusServiceUnavailable, errRsp.StatusCode)
+		}
+	}
+
+	completion, err := bankid.WaitForCompletion(context.Background(), env, rsp.OrderRef, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "198001010001", completion.User.PersonalNumber)
+}
+
+func TestScriptedStartErrors(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	env := newEnv(t, server, bankid.APIVersionV5)
+
+	assert.NoError(t, server.Script(bankid.AuthEndpoint, NewScenario().
+		Error(http.StatusBadRequest, bankid.CodeAlreadyInProgress).
+		Error(http.StatusInternalServerError, bankid.CodeInternalError)))
+
+	_, err := bankid.Auth(env, "198001010001", "127.0.0.1")
+	assert.True(t, errors.Is(err, bankid.ErrAlreadyInProgress))
+	_, err = bankid.Auth(env, "198001010001", "127.0.0.1")
+	assert.True(t, errors.Is(err, bankid.ErrInternalError))
+
+	rsp, err := bankid.Auth(env, "198001010001", "127.0.0.1")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, rsp.OrderRef)
+
+	// Order states are for collect only
+	err = server.Script(bankid.SignEndpoint, NewScenario().Pending(bankid.PendStarted))
+	assert.Error(t, err)
+}
+
+func TestScriptedMalformed(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	env := newEnv(t, server, bankid.APIVersionV5)
+
+	assert.NoError(t, server.Script(bankid.AuthEndpoint, NewScenario().Malformed()))
+	_, err := bankid.Auth(env, "", "127.0.0.1")
+	if assert.Error(t, err) {
+		assert.True(t, strings.Contains(err.Error(), "failed to parse successful response"))
+	}
+
+	rsp, _ := bankid.Auth(env, "", "127.0.0.1")
+	assert.NoError(t, server.Script(bankid.CollectEndpoint, NewScenario().
+		Malformed().
+		Raw(http.StatusBadGateway, "text/html", "<html>Bad Gateway</html>")))
+
+	_, err = bankid.Collect(env, rsp.OrderRef)
+	if assert.Error(t, err) {
+		assert.True(t, strings.Contains(err.Error(), "failed to parse successful response"))
+	}
+
+	_, err = bankid.Collect(env, rsp.OrderRef)
+	var transportErr *bankid.TransportError
+	if assert.True(t, errors.As(err, &transportErr)) {
+		assert.Equal(t, http.StatusBadGateway, transportErr.StatusCode)
+		assert.Equal(t, "text/html", transportErr.ContentType)
+		assert.Equal(t, "<html>Bad Gateway</html>", transportErr.Body)
+	}
+}
+
+func TestScriptedLatency(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	env := newEnv(t, server, bankid.APIVersionV5)
+
+	rsp, _ := bankid.Auth(env, "", "127.0.0.1")
+	assert.NoError(t, server.Script(bankid.CollectEndpoint, NewScenario().
+		Pass().Delay(time.Minute).
+		Pending(bankid.PendStarted).Delay(20*time.Millisecond)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := bankid.CollectContext(ctx, env, rsp.OrderRef)
+	assert.True(t, errors.Is(err, bankid.ErrAborted))
+
+	start := time.Now()
+	collect, err := bankid.Collect(env, rsp.OrderRef)
+	assert.NoError(t, err)
+	assert.Equal(t, bankid.PendStarted, collect.HintCode)
+	assert.True(t, time.Since(start) >= 20*time.Millisecond)
+}
+
+func TestScriptsQueue(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	env := newEnv(t, server, bankid.APIVersionV6)
+
+	// Steps are used up in order across scripts and orders
+	assert.NoError(t, server.Script(bankid.CollectEndpoint, NewScenario().Pending(bankid.PendStarted)))
+	assert.NoError(t, server.Script(bankid.CollectEndpoint, NewScenario().Fail(bankid.FailCertificateErr)))
+
+	first, _ := bankid.Auth(env, "", "127.0.0.1")
+	second, _ := bankid.Auth(env, "", "127.0.0.1")
+
+	collect, _ := bankid.Collect(env, first.OrderRef)
+	assert.Equal(t, bankid.PendStarted, collect.HintCode)
+
+	collect, _ = bankid.Collect(env, second.OrderRef)
+	assert.Equal(t, bankid.FailCertificateErr, collect.HintCode)
+
+	collect, _ = bankid.Collect(env, first.OrderRef)
+	assert.Equal(t, bankid.PendStarted, collect.HintCode)
+}
@@ -0,0 +1,380 @@
+// Package bankidtest - an in-process, stateful fake of the BankID RP API for tests.
+//
+// The server speaks /rp/v5 and /rp/v6.0 over mutual TLS with throwaway certificates,
+// keeps track of every order and lets the test play the user:
+//
+//	server := bankidtest.NewServer(nil)
+//	defer server.Close()
+//
+//	env, _ := server.Environment(bankid.APIVersionV6)
+//	rsp, _ := bankid.Auth(env, "198001010001", "127.0.0.1")
+//
+//	server.Scan(rsp.OrderRef)
+//	server.Sign(rsp.OrderRef)
+//	completion, _ := bankid.WaitForCompletion(ctx, env, rsp.OrderRef, nil)
+package bankidtest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/onlyangel/bankid"
+	"github.com/onlyangel/bankid/personnummer"
+)
+
+// DefaultPersonalNumber - used for completed orders that were started without one
+const DefaultPersonalNumber = "199001010108"
+
+// The phone API of v6, served for completeness. The bankid package has no client for it
+const (
+	phoneAuthEndpoint = "/phone/auth"
+	phoneSignEndpoint = "/phone/sign"
+)
+
+// Config - optional settings for a Server, zero values use the defaults
+type Config struct {
+	Clock     func() time.Time // Default time.Now
+	OrderTTL  time.Duration    // Pending orders fail with expiredTransaction after this, default 3 minutes
+	Retention time.Duration    // Finished orders are forgotten after this, default 3 minutes
+}
+
+// Server - a fake BankID RP API, see the package documentation
+type Server struct {
+	cfg   Config
+	certs *certificates
+	http  *httptest.Server
+
+	mu      sync.Mutex
+	orders  map[string]*Order
+	active  map[string]string // Personal number -> orderRef of its pending order
+	users   map[string]bankid.User
+	scripts map[string][]step // Endpoint -> steps left, see Script()
+}
+
+// NewServer - starts a fake BankID server on a local port, cfg may be nil. Call Close() when done
+func NewServer(cfg *Config) *Server {
+	c := Config{}
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.Clock == nil {
+		c.Clock = time.Now
+	}
+	if c.OrderTTL <= 0 {
+		c.OrderTTL = 3 * time.Minute
+	}
+	if c.Retention <= 0 {
+		c.Retention = 3 * time.Minute
+	}
+
+	certs, err := newCertificates()
+	if err != nil {
+		panic(fmt.Sprintf("bankidtest: could not create certificates: %s", err.Error()))
+	}
+
+	s := &Server{
+		cfg:     c,
+		certs:   certs,
+		orders:  map[string]*Order{},
+		active:  map[string]string{},
+		users:   map[string]bankid.User{},
+		scripts: map[string][]step{},
+	}
+
+	s.http = httptest.NewUnstartedServer(s)
+	s.http.TLS = &tls.Config{
+		Certificates: []tls.Certificate{certs.server},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    certs.caPool,
+	}
+	s.http.StartTLS()
+	return s
+}
+
+// URL - base URL of the server, use instead of bankid.TestBaseURL
+func (s *Server) URL() string {
+	return s.http.URL
+}
+
+// Close - shuts the server down
+func (s *Server) Close() {
+	s.http.Close()
+}
+
+// CACertificate - PEM encoded CA certificate the server certificate is issued by
+func (s *Server) CACertificate() []byte {
+	return s.certs.caPEM
+}
+
+// ClientCertificate - a RP certificate the server accepts
+func (s *Server) ClientCertificate() tls.Certificate {
+	return s.certs.client
+}
+
+// BankIDRoots - the root CA the users certificates in the completion signatures are issued under,
+// for signature.Options
+func (s *Server) BankIDRoots() *x509.CertPool {
+	return s.certs.bankID.Roots
+}
+
+// Environment - a bankid.Environmenter talking to this server, with the RP certificate loaded
+func (s *Server) Environment(apiVersion string, opts ...bankid.Option) (bankid.Environmenter, error) {
+	return bankid.New(append([]bankid.Option{
+		bankid.WithBaseURL(s.URL()),
+		bankid.WithAPIVersion(apiVersion),
+		bankid.WithCA(s.CACertificate()),
+		bankid.WithCertificate(s.ClientCertificate()),
+	}, opts...)...)
+}
+
+// SetUser - the user returned for orders with personalNumber
+func (s *Server) SetUser(user bankid.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[user.PersonalNumber] = user
+}
+
+// Order - a copy of the servers view of an order
+func (s *Server) Order(orderRef string) (Order, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[orderRef]
+	if !ok {
+		return Order{}, false
+	}
+	s.expire(order)
+	return *order, true
+}
+
+// now - the configured clock
+func (s *Server) now() time.Time {
+	return s.cfg.Clock()
+}
+
+var personalNumberFormat = regexp.MustCompile(`^[0-9]{12}$`)
+
+// ServeHTTP - the RP API
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	version, endpoint := splitPath(r.URL.Path)
+
+	if version == "" {
+		writeError(w, http.StatusNotFound, bankid.CodeNotFound, "No such endpoint")
+		return
+	}
+
+	handlers := map[string]func(http.ResponseWriter, string, *bankid.Request){
+		bankid.AuthEndpoint:    s.start,
+		bankid.SignEndpoint:    s.start,
+		bankid.CollectEndpoint: s.collect,
+		bankid.CancelEndpoint:  s.cancel,
+	}
+	if version == bankid.APIVersionV6 {
+		handlers[phoneAuthEndpoint] = s.start
+		handlers[phoneSignEndpoint] = s.start
+	}
+
+	handler, ok := handlers[endpoint]
+	if !ok {
+		writeError(w, http.StatusNotFound, bankid.CodeNotFound, "No such endpoint")
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, bankid.CodeMethodNotAllowed, "Only POST is allowed")
+		return
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, bankid.CodeUnsupportedMediaType, "Content-Type must be application/json")
+		return
+	}
+
+	request := &bankid.Request{}
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		writeError(w, http.StatusBadRequest, bankid.CodeInvalidParameters, "Invalid JSON")
+		return
+	}
+
+	if st, ok := s.nextStep(endpoint); ok && s.play(r.Context(), w, request, st) {
+		return
+	}
+
+	handler(w, version+endpoint, request)
+}
+
+// start - auth, sign, phone/auth and phone/sign
+func (s *Server) start(w http.ResponseWriter, path string, request *bankid.Request) {
+	version, endpoint := splitPath(path)
+	phone := endpoint == phoneAuthEndpoint || endpoint == phoneSignEndpoint
+	sign := endpoint == bankid.SignEndpoint || endpoint == phoneSignEndpoint
+
+	personalNumber := request.PersonalNumber
+	if version == bankid.APIVersionV6 && !phone {
+		if personalNumber != "" {
+			writeError(w, http.StatusBadRequest, bankid.CodeInvalidParameters, "Invalid personalNumber, use requirement.personalNumber")
+			return
+		}
+		if request.Requirement != nil {
+			personalNumber = request.Requirement.PersonalNumber
+		}
+	}
+
+	if details := validate(request, personalNumber, phone, sign); details != "" {
+		writeError(w, http.StatusBadRequest, bankid.CodeInvalidParameters, details)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Both the new and the pending order are aborted
+	if pending, ok := s.active[personalNumber]; ok && personalNumber != "" {
+		if order, ok := s.orders[pending]; ok {
+			s.expire(order)
+			if order.pending() {
+				order.Status = bankid.OrderFailed
+				order.HintCode = bankid.FailCancelled
+				s.finish(order)
+				writeError(w, http.StatusBadRequest, bankid.CodeAlreadyInProgress, "Order already in progress for pno")
+				return
+			}
+		}
+	}
+
+	order := &Order{
+		OrderRef:       uuid(),
+		Endpoint:       endpoint,
+		APIVersion:     version,
+		PersonalNumber: personalNumber,
+		EndUserIP:      request.EndUserIP,
+		Request:        *request,
+		Status:         bankid.OrderPending,
+		HintCode:       bankid.PendOutstandingTransaction,
+		Created:        s.now(),
+	}
+	order.Response = bankid.Response{OrderRef: order.OrderRef}
+	if !phone {
+		order.Response.AutoStartToken = uuid()
+		order.Response.QRStartToken = uuid()
+		order.Response.QRStartSecret = uuid()
+	}
+
+	s.orders[order.OrderRef] = order
+	if personalNumber != "" {
+		s.active[personalNumber] = order.OrderRef
+	}
+
+	writeJSON(w, http.StatusOK, &order.Response)
+}
+
+// validate - the details of the first invalid parameter, empty if all are fine
+func validate(request *bankid.Request, personalNumber string, phone bool, sign bool) string {
+	if phone {
+		if request.CallInitiator != bankid.CallInitiatorUser && request.CallInitiator != bankid.CallInitiatorRP {
+			return "Invalid callInitiator"
+		}
+		if personalNumber == "" {
+			return "Missing personalNumber"
+		}
+	} else if net.ParseIP(request.EndUserIP) == nil {
+		return "Invalid endUserIp"
+	}
+
+	if personalNumber != "" && !(personalNumberFormat.MatchString(personalNumber) && personnummer.Valid(personalNumber)) {
+		return "Invalid personalNumber"
+	}
+
+	if sign && request.UserVisibleData == "" {
+		return "Missing userVisibleData"
+	}
+
+	for field, value := range map[string]string{"userVisibleData": request.UserVisibleData, "userNonVisibleData": request.UserNonVisibleData} {
+		if value == "" {
+			continue
+		}
+		if _, err := base64.StdEncoding.DecodeString(value); err != nil {
+			return "Invalid " + field
+		}
+	}
+
+	if len(request.UserVisibleData) > 40000 {
+		return "Invalid userVisibleData"
+	}
+	if len(request.UserNonVisibleData) > 200000 {
+		return "Invalid userNonVisibleData"
+	}
+	return ""
+}
+
+func (s *Server) collect(w http.ResponseWriter, path string, request *bankid.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[request.OrderRef]
+	if ok {
+		s.expire(order)
+		_, ok = s.orders[request.OrderRef]
+	}
+	if !ok {
+		writeError(w, http.StatusBadRequest, bankid.CodeInvalidParameters, "No such order")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, &bankid.CollectResponse{
+		OrderRef:       order.OrderRef,
+		Status:         order.Status,
+		HintCode:       order.HintCode,
+		CompletionData: order.Completion,
+	})
+}
+
+func (s *Server) cancel(w http.ResponseWriter, path string, request *bankid.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[request.OrderRef]
+	if ok {
+		s.expire(order)
+		ok = order.pending()
+	}
+	if !ok {
+		writeError(w, http.StatusBadRequest, bankid.CodeInvalidParameters, "No such order")
+		return
+	}
+
+	s.finish(order)
+	delete(s.orders, order.OrderRef)
+	writeJSON(w, http.StatusOK, struct{}{})
+}
+
+// splitPath - API version and endpoint, empty version for unknown versions
+func splitPath(path string) (string, string) {
+	for _, v := range []string{bankid.APIVersionV5, bankid.APIVersionV6} {
+		if strings.HasPrefix(path, v+"/") {
+			return v, strings.TrimPrefix(path, v)
+		}
+	}
+	return "", path
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, code string, details string) {
+	writeJSON(w, status, &bankid.ErrorResponse{ErrorCode: code, Details: details})
+}
@@ -0,0 +1,517 @@
+package bankidtest
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/onlyangel/bankid"
+	"github.com/onlyangel/bankid/signature"
+	"github.com/stretchr/testify/assert"
+)
+
+// clock - a settable clock for expiry tests
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newEnv(t *testing.T, server *Server, version string) bankid.Environmenter {
+	env, err := server.Environment(version)
+	if err != nil {
+		t.Fatalf("could not create environment: %s", err.Error())
+	}
+	return env
+}
+
+func TestAuthFlow(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+
+	for _, version := range []string{bankid.APIVersionV5, bankid.APIVersionV6} {
+		env := newEnv(t, server, version)
+
+		rsp, err := bankid.Auth(env, "198001010001", "127.0.0.1")
+		assert.NoError(t, err, version)
+		assert.NotEmpty(t, rsp.OrderRef, version)
+		assert.NotEmpty(t, rsp.AutoStartToken, version)
+		assert.NotEmpty(t, rsp.QRStartToken, version)
+		assert.NotEmpty(t, rsp.QRStartSecret, version)
+
+		collect, err := bankid.Collect(env, rsp.OrderRef)
+		assert.NoError(t, err, version)
+		assert.Equal(t, bankid.OrderPending, collect.Status, version)
+		assert.Equal(t, bankid.PendOutstandingTransaction, collect.HintCode, version)
+
+		assert.NoError(t, server.StartApp(rsp.OrderRef), version)
+		collect, _ = bankid.Collect(env, rsp.OrderRef)
+		assert.Equal(t, bankid.PendStarted, collect.HintCode, version)
+
+		assert.NoError(t, server.Scan(rsp.OrderRef), version)
+		collect, _ = bankid.Collect(env, rsp.OrderRef)
+		assert.Equal(t, bankid.PendUserSign, collect.HintCode, version)
+
+		assert.NoError(t, server.Sign(rsp.OrderRef), version)
+		completion, err := bankid.WaitForCompletion(context.Background(), env, rsp.OrderRef, nil)
+		assert.NoError(t, err, version)
+		assert.Equal(t, "198001010001", completion.User.PersonalNumber, version)
+		assert.NotEmpty(t, completion.Signature, version)
+
+		order, ok := server.Order(rsp.OrderRef)
+		assert.True(t, ok, version)
+		assert.Equal(t, version, order.APIVersion)
+		assert.Equal(t, bankid.AuthEndpoint, order.Endpoint)
+		assert.Equal(t, "198001010001", order.PersonalNumber)
+	}
+}
+
+func TestSignFlowV6(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	env := newEnv(t, server, bankid.APIVersionV6)
+
+	server.SetUser(bankid.User{PersonalNumber: "198001010001", Name: "Karl Karlsson", GivenName: "Karl", Surname: "Karlsson"})
+
+	rsp, err := bankid.SignRequest(env, &bankid.Request{
+		EndUserIP:       "127.0.0.1",
+		UserVisibleData: "Sign this",
+		ReturnRisk:      true,
+		Requirement:     &bankid.Requirement{PersonalNumber: "198001010001"},
+	})
+	assert.NoError(t, err)
+
+	order, _ := server.Order(rsp.OrderRef)
+	assert.Equal(t, "U2lnbiB0aGlz", order.Request.UserVisibleData)
+
+	assert.NoError(t, server.Scan(rsp.OrderRef))
+	assert.NoError(t, server.Sign(rsp.OrderRef))
+
+	collect, err := bankid.Collect(env, rsp.OrderRef)
+	assert.NoError(t, err)
+	assert.Equal(t, bankid.OrderComplete, collect.Status)
+	assert.Equal(t, "Karl Karlsson", collect.CompletionData.User.Name)
+	assert.NotEmpty(t, collect.CompletionData.Device.UHI)
+	assert.NotEmpty(t, collect.CompletionData.BankIDIssueDate)
+	assert.Equal(t, "low", collect.CompletionData.Risk)
+
+	sig, status, err := collect.CompletionData.Verify(&signature.Options{Roots: server.BankIDRoots()})
+	assert.NoError(t, err)
+	assert.Equal(t, signature.StatusGood, status.Status)
+	assert.Equal(t, sig.Certificate.SerialNumber, status.SerialNumber)
+	assert.False(t, sig.SigningTime.IsZero())
+	assert.Equal(t, "Sign this", string(sig.VisibleData))
+	assert.Equal(t, signature.FunctionSigning, sig.Function)
+	assert.Equal(t, "198001010001", sig.Certificate.Subject.SerialNumber)
+
+	collect.CompletionData.User.PersonalNumber = "199001010108"
+	_, err = collect.CompletionData.VerifySignature(&signature.Options{Roots: server.BankIDRoots()})
+	assert.True(t, errors.Is(err, bankid.ErrSignatureUser))
+
+	other := NewServer(nil)
+	defer other.Close()
+	_, err = collect.CompletionData.VerifySignature(&signature.Options{Roots: other.BankIDRoots()})
+	assert.True(t, errors.Is(err, signature.ErrCertificate))
+}
+
+func TestSignWithoutUserVisibleData(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	env := newEnv(t, server, bankid.APIVersionV5)
+
+	_, err := bankid.Sign(env, "", "127.0.0.1", "", "")
+	assert.True(t, errors.Is(err, bankid.ErrInvalidParameters))
+}
+
+func TestVerifySignedData(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	env := newEnv(t, server, bankid.APIVersionV5)
+	opts := &signature.Options{Roots: server.BankIDRoots()}
+
+	complete := func(orderRef string) *bankid.CollectResponse {
+		assert.NoError(t, server.Scan(orderRef))
+		assert.NoError(t, server.Sign(orderRef))
+		collect, err := bankid.Collect(env, orderRef)
+		assert.NoError(t, err)
+		return collect
+	}
+
+	first, err := bankid.Sign(env, "198001010001", "127.0.0.1", "Pay 100 SEK", "invoice=1")
+	assert.NoError(t, err)
+	firstCollect := complete(first.OrderRef)
+
+	second, err := bankid.Sign(env, "199001010108", "127.0.0.1", "Pay 900 SEK", "")
+	assert.NoError(t, err)
+	secondCollect := complete(second.OrderRef)
+
+	sig, err := bankid.VerifySignedData(firstCollect, "Pay 100 SEK", "invoice=1", opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "Pay 100 SEK", string(sig.VisibleData))
+
+	_, err = bankid.VerifySignedData(secondCollect, "Pay 900 SEK", "", opts)
+	assert.NoError(t, err)
+
+	// Mixed up orderRefs
+	_, err = bankid.VerifySignedData(secondCollect, "Pay 100 SEK", "invoice=1", opts)
+	var mismatch *bankid.DataMismatchError
+	assert.True(t, errors.As(err, &mismatch))
+	assert.Equal(t, "userVisibleData", mismatch.Field)
+	assert.Equal(t, "Pay 900 SEK", string(mismatch.Actual))
+	assert.True(t, errors.Is(err, bankid.ErrDataMismatch))
+	assert.True(t, errors.Is(err, signature.ErrInvalid))
+
+	_, err = bankid.VerifySignedData(firstCollect, "Pay 100 SEK", "invoice=2", opts)
+	assert.True(t, errors.As(err, &mismatch))
+	assert.Equal(t, "userNonVisibleData", mismatch.Field)
+
+	auth, err := bankid.Auth(env, "197001010003", "127.0.0.1")
+	assert.NoError(t, err)
+	_, err = bankid.VerifySignedData(complete(auth.OrderRef), "", "", opts)
+	assert.True(t, errors.As(err, &mismatch))
+	assert.Equal(t, "function", mismatch.Field)
+
+	_, err = bankid.VerifySignedData(&bankid.CollectResponse{Status: bankid.OrderPending}, "", "", opts)
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, bankid.ErrDataMismatch))
+}
+
+func TestSignDocuments(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	env := newEnv(t, server, bankid.APIVersionV6)
+	opts := &signature.Options{Roots: server.BankIDRoots()}
+
+	rsp, manifest, err := bankid.SignDocuments(env, "198001010001", "127.0.0.1",
+		bankid.Document{Name: "contract.pdf", Reader: strings.NewReader("%PDF-1.7 contract")})
+	assert.NoError(t, err)
+	assert.NoError(t, server.Scan(rsp.OrderRef))
+	assert.NoError(t, server.Sign(rsp.OrderRef))
+	collect, err := bankid.Collect(env, rsp.OrderRef)
+	assert.NoError(t, err)
+
+	// Stored and verified later
+	stored, err := bankid.ParseManifest(manifest.Canonical())
+	assert.NoError(t, err)
+	sig, err := stored.Verify(collect, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, manifest.Summary(), string(sig.VisibleData))
+	assert.NoError(t, stored.VerifyDocuments(bankid.Document{Name: "contract.pdf", Reader: strings.NewReader("%PDF-1.7 contract")}))
+
+	other, _ := bankid.NewManifest(bankid.Document{Name: "contract.pdf", Reader: strings.NewReader("%PDF-1.7 other")})
+	_, err = other.Verify(collect, opts)
+	assert.True(t, errors.Is(err, bankid.ErrDataMismatch))
+}
+
+func TestEvidence(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	env := newEnv(t, server, bankid.APIVersionV5)
+	opts := &signature.Options{Roots: server.BankIDRoots()}
+
+	rsp, manifest, err := bankid.SignDocuments(env, "198001010001", "127.0.0.1",
+		bankid.Document{Name: "contract.pdf", Reader: strings.NewReader("%PDF-1.7 contract")})
+	assert.NoError(t, err)
+	assert.NoError(t, server.Scan(rsp.OrderRef))
+	assert.NoError(t, server.Sign(rsp.OrderRef))
+	collect, _ := bankid.Collect(env, rsp.OrderRef)
+
+	evidence, err := manifest.Evidence(collect, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, rsp.OrderRef, evidence.OrderRef)
+	assert.False(t, evidence.SignedAt.IsZero())
+
+	archived, err := evidence.Marshal()
+	assert.NoError(t, err)
+
+	// Later
+	restored, err := bankid.ParseEvidence(archived)
+	assert.NoError(t, err)
+	sig, status, err := bankid.VerifyEvidence(restored, server.BankIDRoots())
+	assert.NoError(t, err)
+	assert.Equal(t, "198001010001", sig.Certificate.Subject.SerialNumber)
+	assert.Equal(t, evidence.SignedAt, sig.SigningTime)
+	assert.Equal(t, signature.StatusGood, status.Status)
+	assert.NoError(t, restored.Manifest.VerifyDocuments(bankid.Document{Name: "contract.pdf", Reader: strings.NewReader("%PDF-1.7 contract")}))
+
+	tamper := func(change func(e *bankid.Evidence)) error {
+		e, _ := bankid.ParseEvidence(archived)
+		change(e)
+		_, _, err := bankid.VerifyEvidence(e, server.BankIDRoots())
+		return err
+	}
+	err = tamper(func(e *bankid.Evidence) { e.SignedAt = e.SignedAt.Add(time.Minute) })
+	assert.True(t, errors.Is(err, bankid.ErrEvidence))
+	err = tamper(func(e *bankid.Evidence) { e.SignedAt = e.SignedAt.AddDate(0, 0, 2) })
+	assert.True(t, errors.Is(err, signature.ErrCertificate))
+	err = tamper(func(e *bankid.Evidence) { e.Manifest.Documents[0].SHA256 = strings.Repeat("0", 64) })
+	assert.True(t, errors.Is(err, bankid.ErrDataMismatch))
+	err = tamper(func(e *bankid.Evidence) { e.Completion.User.PersonalNumber = "199001010108" })
+	assert.True(t, errors.Is(err, bankid.ErrSignatureUser))
+	err = tamper(func(e *bankid.Evidence) { e.Completion.OCSPResponse = "" })
+	assert.True(t, errors.Is(err, signature.ErrOCSP))
+
+	other := NewServer(nil)
+	defer other.Close()
+	_, _, err = bankid.VerifyEvidence(restored, other.BankIDRoots())
+	assert.True(t, errors.Is(err, signature.ErrCertificate))
+
+	// Not the data of the order
+	_, err = bankid.NewEvidence(collect, "Pay 100 SEK", "", opts)
+	assert.True(t, errors.Is(err, bankid.ErrDataMismatch))
+}
+
+func TestInvalidEndUserIP(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	env := newEnv(t, server, bankid.APIVersionV5)
+
+	_, err := bankid.Auth(env, "", "not an ip")
+	assert.True(t, errors.Is(err, bankid.ErrInvalidParameters))
+}
+
+func TestAlreadyInProgress(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	env := newEnv(t, server, bankid.APIVersionV5)
+
+	first, err := bankid.Auth(env, "198001010001", "127.0.0.1")
+	assert.NoError(t, err)
+
+	_, err = bankid.Auth(env, "198001010001", "127.0.0.1")
+	assert.True(t, errors.Is(err, bankid.ErrAlreadyInProgress))
+
+	collect, err := bankid.Collect(env, first.OrderRef)
+	assert.NoError(t, err)
+	assert.Equal(t, bankid.OrderFailed, collect.Status)
+	assert.Equal(t, bankid.FailCancelled, collect.HintCode)
+
+	// Free again once the pending order is aborted
+	_, err = bankid.Auth(env, "198001010001", "127.0.0.1")
+	assert.NoError(t, err)
+
+	// Orders without a personal number never collide
+	_, err = bankid.Auth(env, "", "127.0.0.1")
+	assert.NoError(t, err)
+	_, err = bankid.Auth(env, "", "127.0.0.1")
+	assert.NoError(t, err)
+}
+
+func TestExpiry(t *testing.T) {
+	c := &clock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	server := NewServer(&Config{Clock: c.Now, OrderTTL: time.Minute, Retention: time.Minute})
+	defer server.Close()
+	env := newEnv(t, server, bankid.APIVersionV5)
+
+	rsp, err := bankid.Auth(env, "198001010001", "127.0.0.1")
+	assert.NoError(t, err)
+
+	c.Add(time.Minute)
+	collect, err := bankid.Collect(env, rsp.OrderRef)
+	assert.NoError(t, err)
+	assert.Equal(t, bankid.OrderFailed, collect.Status)
+	assert.Equal(t, bankid.FailExpiredTransaction, collect.HintCode)
+
+	assert.True(t, errors.Is(server.Scan(rsp.OrderRef), ErrInvalidTransition))
+
+	// The personal number is free after expiry
+	_, err = bankid.Auth(env, "198001010001", "127.0.0.1")
+	assert.NoError(t, err)
+
+	c.Add(time.Minute)
+	_, err = bankid.Collect(env, rsp.OrderRef)
+	assert.True(t, errors.Is(err, bankid.ErrInvalidParameters))
+
+	_, ok := server.Order(rsp.OrderRef)
+	assert.False(t, ok)
+	assert.Equal(t, ErrNoSuchOrder, server.Scan(rsp.OrderRef))
+}
+
+func TestInvalidTransitions(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	env := newEnv(t, server, bankid.APIVersionV5)
+
+	rsp, err := bankid.Auth(env, "", "127.0.0.1")
+	assert.NoError(t, err)
+
+	assert.True(t, errors.Is(server.Sign(rsp.OrderRef), ErrInvalidTransition), "sign before scan")
+	assert.True(t, errors.Is(server.CertificateError(rsp.OrderRef), ErrInvalidTransition), "certificateErr before start")
+
+	assert.NoError(t, server.Scan(rsp.OrderRef))
+	assert.True(t, errors.Is(server.StartApp(rsp.OrderRef), ErrInvalidTransition), "started after userSign")
+	assert.True(t, errors.Is(server.NoClient(rsp.OrderRef), ErrInvalidTransition), "noClient after userSign")
+
+	assert.NoError(t, server.SignAs(rsp.OrderRef, &bankid.User{PersonalNumber: "199001010108"}))
+	assert.True(t, errors.Is(server.UserCancel(rsp.OrderRef), ErrInvalidTransition), "cancel after complete")
+
+	assert.Equal(t, ErrNoSuchOrder, server.Scan("131daac9-16c6-4618-beb0-365768f37288"))
+}
+
+func TestSignAsOtherUser(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	env := newEnv(t, server, bankid.APIVersionV5)
+
+	rsp, _ := bankid.Auth(env, "198001010001", "127.0.0.1")
+	assert.NoError(t, server.Scan(rsp.OrderRef))
+
+	err := server.SignAs(rsp.OrderRef, &bankid.User{PersonalNumber: "199001010108"})
+	assert.True(t, errors.Is(err, ErrInvalidTransition))
+}
+
+func TestUserCancelAndTimeout(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	env := newEnv(t, server, bankid.APIVersionV5)
+
+	for hintCode, action := range map[string]func(string) error{
+		bankid.FailUserCancel:         server.UserCancel,
+		bankid.FailExpiredTransaction: server.Timeout,
+		bankid.FailStartFailed:        server.FailStart,
+	} {
+		rsp, err := bankid.Auth(env, "", "127.0.0.1")
+		assert.NoError(t, err)
+		assert.NoError(t, action(rsp.OrderRef))
+
+		_, err = bankid.WaitForCompletion(context.Background(), env, rsp.OrderRef, nil)
+		assert.True(t, errors.Is(err, bankid.ErrOrderFailed), hintCode)
+
+		var failed *bankid.OrderFailedError
+		if assert.True(t, errors.As(err, &failed), hintCode) {
+			assert.Equal(t, hintCode, failed.HintCode)
+		}
+	}
+}
+
+func TestCancel(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	env := newEnv(t, server, bankid.APIVersionV5)
+
+	rsp, _ := bankid.Auth(env, "198001010001", "127.0.0.1")
+	assert.NoError(t, bankid.Cancel(env, rsp.OrderRef))
+
+	_, err := bankid.Collect(env, rsp.OrderRef)
+	assert.True(t, errors.Is(err, bankid.ErrInvalidParameters))
+
+	err = bankid.Cancel(env, rsp.OrderRef)
+	assert.True(t, errors.Is(err, bankid.ErrInvalidParameters))
+
+	_, err = bankid.Auth(env, "198001010001", "127.0.0.1")
+	assert.NoError(t, err)
+}
+
+func post(t *testing.T, env bankid.Environmenter, endpoint string, body interface{}) *http.Response {
+	req, err := env.NewRequest(context.Background(), endpoint, body)
+	if err != nil {
+		t.Fatalf("could not create request: %s", err.Error())
+	}
+	rsp, err := env.NewClient().Do(req)
+	if err != nil {
+		t.Fatalf("could not call %s: %s", endpoint, err.Error())
+	}
+	return rsp
+}
+
+func TestPhone(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	env := newEnv(t, server, bankid.APIVersionV6)
+
+	rsp := post(t, env, phoneAuthEndpoint, &bankid.Request{PersonalNumber: "198001010001", CallInitiator: bankid.CallInitiatorRP})
+	defer rsp.Body.Close()
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+
+	started := bankid.Response{}
+	json.NewDecoder(rsp.Body).Decode(&started)
+	assert.NotEmpty(t, started.OrderRef)
+	assert.Empty(t, started.AutoStartToken)
+	assert.Empty(t, started.QRStartToken)
+
+	assert.NoError(t, server.Scan(started.OrderRef))
+	assert.NoError(t, server.Sign(started.OrderRef))
+	completion, err := bankid.WaitForCompletion(context.Background(), env, started.OrderRef, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "198001010001", completion.User.PersonalNumber)
+
+	order, _ := server.Order(started.OrderRef)
+	assert.Equal(t, phoneAuthEndpoint, order.Endpoint)
+
+	for _, body := range []*bankid.Request{
+		{PersonalNumber: "198001010001"},
+		{CallInitiator: bankid.CallInitiatorUser},
+	} {
+		rsp := post(t, env, phoneSignEndpoint, body)
+		rsp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, rsp.StatusCode)
+	}
+
+	rsp = post(t, env, phoneSignEndpoint, &bankid.Request{PersonalNumber: "198001010001", CallInitiator: bankid.CallInitiatorUser, UserVisibleData: "U2lnbiB0aGlz"})
+	rsp.Body.Close()
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+}
+
+func TestPhoneNotInV5(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	env := newEnv(t, server, bankid.APIVersionV5)
+
+	rsp := post(t, env, phoneAuthEndpoint, &bankid.Request{PersonalNumber: "198001010001", CallInitiator: bankid.CallInitiatorRP})
+	rsp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, rsp.StatusCode)
+}
+
+func TestMethodAndContentType(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	env := newEnv(t, server, bankid.APIVersionV5)
+
+	req, _ := env.NewRequest(context.Background(), bankid.AuthEndpoint, &bankid.Request{})
+	req.Method = http.MethodGet
+	rsp, err := env.NewClient().Do(req)
+	assert.NoError(t, err)
+	rsp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, rsp.StatusCode)
+
+	req, _ = env.NewRequest(context.Background(), bankid.AuthEndpoint, &bankid.Request{})
+	req.Header.Set("Content-Type", "text/plain")
+	rsp, err = env.NewClient().Do(req)
+	assert.NoError(t, err)
+	rsp.Body.Close()
+	assert.Equal(t, http.StatusUnsupportedMediaType, rsp.StatusCode)
+}
+
+func TestRequiresClientCertificate(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+
+	// Trusts the server, but has no RP certificate
+	env, err := bankid.New(
+		bankid.WithBaseURL(server.URL()),
+		bankid.WithHTTPClient(&http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: server.certs.caPool},
+		}}),
+	)
+	assert.NoError(t, err)
+
+	_, err = bankid.Auth(env, "", "127.0.0.1")
+	assert.Error(t, err)
+}
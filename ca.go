@@ -0,0 +1,34 @@
+package bankid
+
+import (
+	_ "embed" // CA certificates
+	"fmt"
+	"strings"
+)
+
+// Names of the embedded CA certificates, see CACertificate()
+const (
+	CATest       = "test"
+	CAProduction = "production"
+)
+
+// The BankID SSL root certificates from the CA/ directory
+var (
+	//go:embed CA/test.crt
+	caTest []byte
+
+	//go:embed CA/production.crt
+	caProduction []byte
+)
+
+// CACertificate - the PEM encoded BankID SSL root certificate, CATest or CAProduction
+func CACertificate(name string) ([]byte, error) {
+	switch strings.ToLower(name) {
+	case CATest:
+		return caTest, nil
+	case CAProduction:
+		return caProduction, nil
+	default:
+		return nil, fmt.Errorf("%s is not a known CA certificate", name)
+	}
+}
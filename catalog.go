@@ -0,0 +1,127 @@
+package bankid
+
+// Messages in several languages, picked per user
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// Catalog - messages in several languages with fallback chains for missing keys.
+// Set it up before use, after that it is safe for concurrent use
+type Catalog struct {
+	languages []language.Tag // In the order added, the first is the default
+	messages  map[language.Tag]map[string]string
+	fallbacks map[language.Tag][]language.Tag
+}
+
+// NewCatalog - a catalog with the official English and Swedish messages.
+// English is the default. Other languages fall back to their parents and then English,
+// so a sv-SE file only needs the keys it rewrites
+func NewCatalog() *Catalog {
+	c := &Catalog{
+		messages:  map[language.Tag]map[string]string{},
+		fallbacks: map[language.Tag][]language.Tag{},
+	}
+	c.Add(language.English, messages_EN)
+	c.Add(language.Swedish, messages_SE)
+	return c
+}
+
+// Add - messages for a language, merged with the messages already added for it
+func (c *Catalog) Add(tag language.Tag, messages map[string]string) {
+	existing, ok := c.messages[tag]
+	if !ok {
+		existing = map[string]string{}
+		c.messages[tag] = existing
+		c.languages = append(c.languages, tag)
+	}
+	for key, msg := range messages {
+		existing[key] = msg
+	}
+}
+
+// SetFallback - the languages to look in, in order, for keys missing in tag.
+// Replaces the default fallback to the parents of tag and English, no fallbacks turns it off
+func (c *Catalog) SetFallback(tag language.Tag, fallbacks ...language.Tag) {
+	c.fallbacks[tag] = fallbacks
+}
+
+// Languages - the languages in the catalog, the default first
+func (c *Catalog) Languages() []language.Tag {
+	return append([]language.Tag{}, c.languages...)
+}
+
+// Messages - the messages of the closest language in the catalog, the default if none is close
+func (c *Catalog) Messages(tags ...language.Tag) *Messages {
+	if len(c.languages) == 0 {
+		return &Messages{}
+	}
+
+	_, index, confidence := language.NewMatcher(c.languages).Match(tags...)
+	if confidence == language.No {
+		index = 0
+	}
+	return c.messagesFor(c.languages[index])
+}
+
+// Negotiate - Messages() for a HTTP Accept-Language header, the default if it can't be parsed
+func (c *Catalog) Negotiate(acceptLanguage string) *Messages {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil {
+		tags = nil
+	}
+	return c.Messages(tags...)
+}
+
+// Lookup - the messages of exactly this language, or its base language, like NewMessages()
+func (c *Catalog) Lookup(lang string) (*Messages, error) {
+	tag, err := parseLanguage(lang)
+	if err != nil {
+		return nil, fmt.Errorf("%s it not a supported language", lang)
+	}
+	if _, ok := c.messages[tag]; ok {
+		return c.messagesFor(tag), nil
+	}
+
+	base, _ := tag.Base()
+	for _, supported := range c.languages {
+		if b, _ := supported.Base(); b == base {
+			return c.messagesFor(supported), nil
+		}
+	}
+	return nil, fmt.Errorf("%s it not a supported language", lang)
+}
+
+// parseLanguage - a BCP 47 tag, or "se" for Swedish. ISO 639 "se" is Northern Sami,
+// but "se" is the country and what the messages were always called
+func parseLanguage(lang string) (language.Tag, error) {
+	if strings.ToLower(lang) == "se" {
+		return language.Swedish, nil
+	}
+	return language.Parse(lang)
+}
+
+// messagesFor - the messages of tag with its fallback chain
+func (c *Catalog) messagesFor(tag language.Tag) *Messages {
+	chain, ok := c.fallbacks[tag]
+	if !ok {
+		// e.g sv-SE, sv, en. Parents missing in the catalog are skipped below
+		for parent := tag.Parent(); parent != language.Und; parent = parent.Parent() {
+			chain = append(chain, parent)
+		}
+		chain = append(chain, language.English)
+	}
+
+	m := &Messages{lang: tag, chain: []map[string]string{c.messages[tag]}}
+	seen := map[language.Tag]bool{tag: true}
+	for _, fallback := range chain {
+		if msgs, ok := c.messages[fallback]; ok && !seen[fallback] {
+			m.chain = append(m.chain, msgs)
+			seen[fallback] = true
+		}
+	}
+	return m
+}
@@ -0,0 +1,223 @@
+package bankid
+
+// Loading catalogs from JSON and gettext PO files
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// LoadJSON - messages for a language from a JSON object of keys and texts, e.g {"RFA1": "..."}.
+// Layered over the messages already in the catalog, so a file with a few keys overrides just those
+func (c *Catalog) LoadJSON(tag language.Tag, r io.Reader) error {
+	messages := map[string]string{}
+	if err := json.NewDecoder(r).Decode(&messages); err != nil {
+		return fmt.Errorf("could not parse JSON messages: %s", err.Error())
+	}
+	c.Add(tag, messages)
+	return nil
+}
+
+// LoadPO - messages for a language from a gettext PO file, with the keys as msgid.
+// Fuzzy and untranslated entries are skipped. Layered over the messages already in the catalog
+func (c *Catalog) LoadPO(tag language.Tag, r io.Reader) error {
+	messages, err := parsePO(r)
+	if err != nil {
+		return fmt.Errorf("could not parse PO messages: %s", err.Error())
+	}
+	c.Add(tag, messages)
+	return nil
+}
+
+// LoadFS - every *.json and *.po file in the root of fsys, named by language, e.g fi.json, nb.po or sv-SE.json.
+// Regional files only need the keys that differ, the rest comes from the base language. Use fs.Sub() for a directory
+func (c *Catalog) LoadFS(fsys fs.FS) error {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return fmt.Errorf("could not read messages: %s", err.Error())
+	}
+
+	for _, entry := range entries {
+		ext := path.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".json" && ext != ".po") {
+			continue
+		}
+
+		tag, err := parseLanguage(strings.TrimSuffix(entry.Name(), ext))
+		if err != nil {
+			return fmt.Errorf("could not load %s: not named by language", entry.Name())
+		}
+
+		f, err := fsys.Open(entry.Name())
+		if err != nil {
+			return fmt.Errorf("could not load %s: %s", entry.Name(), err.Error())
+		}
+		if ext == ".json" {
+			err = c.LoadJSON(tag, f)
+		} else {
+			err = c.LoadPO(tag, f)
+		}
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("could not load %s: %s", entry.Name(), err.Error())
+		}
+	}
+	return nil
+}
+
+// CatalogError - keys missing from, or unknown to, the languages of a catalog
+type CatalogError struct {
+	Missing map[language.Tag][]string // RFA keys without a text in the language or its parents, e.g sv for sv-SE. Other fallbacks not counted
+	Unknown map[language.Tag][]string // Keys that are not RFA keys, most likely typos
+}
+
+// Error -
+func (e *CatalogError) Error() string {
+	var problems []string
+	for _, report := range []struct {
+		what string
+		keys map[language.Tag][]string
+	}{{"missing", e.Missing}, {"unknown", e.Unknown}} {
+		for tag, keys := range report.keys {
+			problems = append(problems, fmt.Sprintf("%s %s: %s", tag, report.what, strings.Join(keys, ", ")))
+		}
+	}
+	sort.Strings(problems)
+	return "invalid message catalog: " + strings.Join(problems, "; ")
+}
+
+// Validate - reports the RFA keys each language lacks and the keys that aren't RFA keys, as a *CatalogError.
+// nil when every language is complete
+func (c *Catalog) Validate() error {
+	known := map[string]bool{}
+	for _, key := range RFAKeys {
+		known[key] = true
+	}
+
+	e := &CatalogError{Missing: map[language.Tag][]string{}, Unknown: map[language.Tag][]string{}}
+	for _, tag := range c.languages {
+		messages := c.messages[tag]
+		for _, key := range RFAKeys {
+			if c.inherited(tag, key) == "" {
+				e.Missing[tag] = append(e.Missing[tag], key)
+			}
+		}
+		for key := range messages {
+			if !known[key] {
+				e.Unknown[tag] = append(e.Unknown[tag], key)
+			}
+		}
+		sort.Strings(e.Unknown[tag])
+	}
+
+	if len(e.Missing) == 0 && len(e.Unknown) == 0 {
+		return nil
+	}
+	return e
+}
+
+// inherited - the text for key in tag or the closest of its parents in the catalog
+func (c *Catalog) inherited(tag language.Tag, key string) string {
+	for ; tag != language.Und; tag = tag.Parent() {
+		if msg := c.messages[tag][key]; msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+// poEntry - one msgid and its msgstr
+type poEntry struct {
+	msgid, msgstr strings.Builder
+	hasID, hasStr bool
+	fuzzy         bool
+}
+
+// parsePO - msgid to msgstr of a PO file. Only what message catalogs need: comments,
+// the fuzzy flag, msgctxt and multi line strings. Plurals are not supported
+func parsePO(r io.Reader) (map[string]string, error) {
+	messages := map[string]string{}
+
+	var entry *poEntry
+	var current *strings.Builder // Where continuation strings go
+	fuzzy := false               // Flags are comments before the entry they belong to
+
+	flush := func() {
+		if entry != nil && !entry.fuzzy && entry.msgid.Len() > 0 && entry.msgstr.Len() > 0 {
+			messages[entry.msgid.String()] = entry.msgstr.String()
+		}
+		entry = &poEntry{fuzzy: fuzzy}
+		fuzzy = false
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case text == "":
+			continue
+		case strings.HasPrefix(text, "#"):
+			if strings.HasPrefix(text, "#,") && strings.Contains(text, "fuzzy") {
+				fuzzy = true
+			}
+			continue
+		case strings.HasPrefix(text, "msgid_plural"), strings.HasPrefix(text, "msgstr["):
+			return nil, fmt.Errorf("line %d: plural forms are not supported", line)
+		}
+
+		keyword, value := "", text
+		if !strings.HasPrefix(text, `"`) {
+			fields := strings.SplitN(text, " ", 2)
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: expected a keyword and a string", line)
+			}
+			keyword, value = fields[0], strings.TrimSpace(fields[1])
+		}
+
+		str, err := strconv.Unquote(value)
+		if err != nil || !strings.HasPrefix(value, `"`) {
+			return nil, fmt.Errorf("line %d: invalid string %s", line, value)
+		}
+
+		switch keyword {
+		case "":
+			if current == nil {
+				return nil, fmt.Errorf("line %d: string without keyword", line)
+			}
+		case "msgctxt":
+			flush()
+			current = &strings.Builder{} // Contexts are not used, read and dropped
+		case "msgid":
+			if entry == nil || entry.hasID {
+				flush()
+			}
+			entry.hasID = true
+			current = &entry.msgid
+		case "msgstr":
+			if entry == nil || !entry.hasID || entry.hasStr {
+				return nil, fmt.Errorf("line %d: msgstr without msgid", line)
+			}
+			entry.hasStr = true
+			current = &entry.msgstr
+		default:
+			return nil, fmt.Errorf("line %d: unknown keyword %s", line, keyword)
+		}
+		current.WriteString(str)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return messages, nil
+}
@@ -0,0 +1,119 @@
+package bankid
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+const finnishPO = `# Finnish messages
+msgid ""
+msgstr ""
+"Language: fi\n"
+"Content-Type: text/plain; charset=UTF-8\n"
+
+#. Shown while waiting for the app
+msgid "RFA1"
+msgstr "Käynnistä BankID-sovellus."
+
+msgctxt "collect"
+msgid "RFA9"
+msgstr ""
+"Syötä turvakoodisi BankID-sovellukseen "
+"ja valitse \"Tunnistaudu\" tai \"Allekirjoita\"."
+
+#, fuzzy
+msgid "RFA3"
+msgstr "Toiminto peruutettu?"
+
+msgid "RFA6"
+msgstr ""
+`
+
+func TestParsePO(t *testing.T) {
+	messages, err := parsePO(strings.NewReader(finnishPO))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		RFA1: "Käynnistä BankID-sovellus.",
+		RFA9: `Syötä turvakoodisi BankID-sovellukseen ja valitse "Tunnistaudu" tai "Allekirjoita".`,
+	}, messages)
+
+	for name, po := range map[string]string{
+		"plural":         "msgid \"RFA1\"\nmsgid_plural \"RFA1s\"\nmsgstr[0] \"a\"",
+		"no msgid":       "msgstr \"a\"",
+		"unquoted":       "msgid RFA1",
+		"no keyword":     "\"a\"",
+		"unknown":        "msgfoo \"a\"",
+		"double msgstr":  "msgid \"RFA1\"\nmsgstr \"a\"\nmsgstr \"b\"",
+		"broken escapes": "msgid \"RFA1\\\"",
+	} {
+		_, err := parsePO(strings.NewReader(po))
+		assert.NotNil(t, err, name)
+	}
+}
+
+func TestLoadFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"fi.po":     {Data: []byte(finnishPO)},
+		"de.json":   {Data: []byte(`{"RFA1": "Starten Sie Ihre BankID-App.", "RFA99": "Tippfehler"}`)},
+		"sv.json":   {Data: []byte(`{"RFA1": "Öppna BankID-appen"}`)}, // Override of the official text
+		"README.md": {Data: []byte("not messages")},
+		"old/en.po": {Data: []byte("not read")},
+	}
+
+	c := NewCatalog()
+	assert.Nil(t, c.LoadFS(fsys))
+	assert.Equal(t, []language.Tag{language.English, language.Swedish, language.German, language.Finnish}, c.Languages())
+
+	assert.Equal(t, "Käynnistä BankID-sovellus.", c.Negotiate("fi").Msg(RFA1))
+	assert.Equal(t, messages_EN[RFA6], c.Negotiate("fi").Msg(RFA6)) // Untranslated, from English
+	assert.Equal(t, "Starten Sie Ihre BankID-App.", c.Negotiate("de-AT").Msg(RFA1))
+	assert.Equal(t, "Öppna BankID-appen", c.Negotiate("sv").Msg(RFA1))
+	assert.Equal(t, messages_SE[RFA9], c.Negotiate("sv").Msg(RFA9))
+
+	err := c.Validate()
+	var catalogErr *CatalogError
+	assert.True(t, errors.As(err, &catalogErr))
+	assert.NotContains(t, catalogErr.Missing, language.Swedish)
+	assert.NotContains(t, catalogErr.Missing, language.English)
+	assert.Equal(t, 19, len(catalogErr.Missing[language.Finnish]))
+	assert.Equal(t, []string{RFA2, RFA3, RFA4}, catalogErr.Missing[language.German][:3])
+	assert.Equal(t, map[language.Tag][]string{language.German: {"RFA99"}}, catalogErr.Unknown)
+	assert.Contains(t, err.Error(), "de unknown: RFA99")
+
+	assert.Nil(t, NewCatalog().Validate())
+
+	err = NewCatalog().LoadFS(fstest.MapFS{"finnish.json": {Data: []byte(`{}`)}})
+	assert.NotNil(t, err)
+	err = NewCatalog().LoadFS(fstest.MapFS{"fi.json": {Data: []byte(`["RFA1"]`)}})
+	assert.NotNil(t, err)
+}
+
+func TestLoadFSRegional(t *testing.T) {
+	c := NewCatalog()
+	assert.Nil(t, c.LoadFS(fstest.MapFS{
+		"sv-SE.json": {Data: []byte(`{"RFA1": "Öppna BankID-appen"}`)},
+		"en-GB.json": {Data: []byte(`{"RFA1": "Open the BankID app"}`)},
+	}))
+
+	sv := c.Negotiate("sv-SE,sv;q=0.9")
+	assert.Equal(t, language.MustParse("sv-SE"), sv.Language())
+	assert.Equal(t, "Öppna BankID-appen", sv.Msg(RFA1))
+	assert.Equal(t, messages_SE[RFA9], sv.Msg(RFA9)) // From Swedish, not English
+	assert.Equal(t, messages_SE[RFA1], c.Negotiate("sv").Msg(RFA1))
+
+	en := c.Negotiate("en-GB")
+	assert.Equal(t, "Open the BankID app", en.Msg(RFA1))
+	assert.Equal(t, messages_EN[RFA9], en.Msg(RFA9))
+
+	// Nothing is missing, the rest comes from sv and en
+	assert.Nil(t, c.Validate())
+
+	// An explicit fallback still wins
+	c.SetFallback(language.MustParse("sv-SE"), language.English)
+	assert.Equal(t, messages_EN[RFA9], c.Negotiate("sv-SE").Msg(RFA9))
+}
@@ -0,0 +1,66 @@
+package bankid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestSwedishLanguageCodes(t *testing.T) {
+	for _, lang := range []string{"sv", "SV", "se", "sv-SE", "sv-FI"} {
+		m, err := NewMessages(lang)
+		assert.Nil(t, err, lang)
+		assert.Equal(t, language.Swedish, m.Language(), lang)
+		assert.Equal(t, messages_SE[RFA1], m.Msg(RFA1), lang)
+	}
+
+	m, err := NewMessages("en-GB")
+	assert.Nil(t, err)
+	assert.Equal(t, language.English, m.Language())
+
+	_, err = NewMessages("not a language")
+	assert.NotNil(t, err)
+}
+
+func TestNegotiateMessages(t *testing.T) {
+	for header, expected := range map[string]language.Tag{
+		"sv-SE,sv;q=0.9,en-US;q=0.8,en;q=0.7": language.Swedish,
+		"de-DE,de;q=0.9,en;q=0.5":             language.English,
+		"nb-NO,nb;q=0.9":                      language.English, // Not matched with Swedish
+		"de":                                  language.English,
+		"":                                    language.English,
+		";;garbage":                           language.English,
+		"fi, sv;q=0.5":                        language.Swedish,
+	} {
+		assert.Equal(t, expected, NegotiateMessages(header).Language(), header)
+	}
+}
+
+func TestCatalogFallback(t *testing.T) {
+	finnish := language.Finnish
+	c := NewCatalog()
+	c.Add(finnish, map[string]string{RFA1: "Käynnistä BankID-sovellus."})
+
+	fi := c.Negotiate("fi-FI")
+	assert.Equal(t, finnish, fi.Language())
+	assert.Equal(t, "Käynnistä BankID-sovellus.", fi.Msg(RFA1))
+	assert.Equal(t, messages_EN[RFA9], fi.Msg(RFA9)) // Falls back to English by default
+
+	c.SetFallback(finnish, language.Swedish)
+	assert.Equal(t, messages_SE[RFA9], c.Negotiate("fi").Msg(RFA9))
+
+	c.SetFallback(finnish)
+	assert.Equal(t, "", c.Negotiate("fi").Msg(RFA9))
+
+	// Add merges with the messages already there
+	c.Add(language.Swedish, map[string]string{RFA1: "Öppna BankID-appen"})
+	sv, err := c.Lookup("se")
+	assert.Nil(t, err)
+	assert.Equal(t, "Öppna BankID-appen", sv.Msg(RFA1))
+	assert.Equal(t, messages_SE[RFA9], sv.Msg(RFA9))
+
+	// The official messages are untouched
+	assert.Equal(t, "Starta BankID-appen", messages_SE[RFA1])
+	assert.Equal(t, []language.Tag{language.English, language.Swedish, finnish}, c.Languages())
+}
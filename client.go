@@ -0,0 +1,140 @@
+package bankid
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// TLSConfigurer - implemented by environments that can share their TLS configuration,
+// lets a Client build its own pooled transport
+type TLSConfigurer interface {
+	TLSConfig() *tls.Config
+}
+
+// ClientConfig - connection pool settings for a Client, zero values use the defaults
+type ClientConfig struct {
+	MaxIdleConns        int           // Default 100
+	MaxIdleConnsPerHost int           // Default 10, BankID is a single host
+	MaxConnsPerHost     int           // Default 0, no limit
+	IdleConnTimeout     time.Duration // Default 90s
+	Timeout             time.Duration // Default from the environment, 10s unless WithTimeout() was used
+	HTTP2               bool          // Negotiate HTTP/2 over the mutual TLS connection
+}
+
+// Client - long lived BankID client with one pooled transport.
+// Reuses connections, and the mutual TLS handshake, between calls.
+// Safe for concurrent use, create one per environment and keep it.
+type Client struct {
+	env  Environmenter
+	http *http.Client
+}
+
+// NewClient - a Client for env, cfg may be nil.
+// Environments without a TLSConfig(), e.g when using WithHTTPClient(),
+// get one client from env.NewClient() that is reused
+func NewClient(env Environmenter, cfg *ClientConfig) *Client {
+	if cfg == nil {
+		cfg = &ClientConfig{}
+	}
+
+	var tlsConfig *tls.Config
+	if configurer, ok := env.(TLSConfigurer); ok {
+		tlsConfig = configurer.TLSConfig()
+	}
+	if tlsConfig == nil {
+		return &Client{
+			env:  env,
+			http: env.NewClient(),
+		}
+	}
+
+	var proxy func(*http.Request) (*url.URL, error) // No proxy unless asked for, see WithProxy()
+	dialTimeout := 5 * time.Second
+	timeout := 10 * time.Second
+	if e, ok := env.(*environment); ok {
+		proxy, dialTimeout, timeout = e.proxy, e.dialTimeout, e.timeout
+	}
+	if cfg.Timeout != 0 {
+		timeout = cfg.Timeout
+	}
+
+	transport := &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   dialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: 5 * time.Second,
+		MaxIdleConns:        withDefault(cfg.MaxIdleConns, 100),
+		MaxIdleConnsPerHost: withDefault(cfg.MaxIdleConnsPerHost, 10),
+		MaxConnsPerHost:     cfg.MaxConnsPerHost,
+		IdleConnTimeout:     cfg.IdleConnTimeout,
+		ForceAttemptHTTP2:   cfg.HTTP2,
+	}
+	if transport.IdleConnTimeout == 0 {
+		transport.IdleConnTimeout = 90 * time.Second
+	}
+
+	return &Client{
+		env: env,
+		http: &http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+		},
+	}
+}
+
+func withDefault(value int, def int) int {
+	if value == 0 {
+		return def
+	}
+	return value
+}
+
+// Auth - see the package level Auth()
+func (c *Client) Auth(ctx context.Context, personalNumber string, userIP string) (*Response, error) {
+	return c.AuthRequest(ctx, &Request{
+		PersonalNumber: personalNumber,
+		EndUserIP:      userIP,
+	})
+}
+
+// AuthRequest - see the package level AuthRequest()
+func (c *Client) AuthRequest(ctx context.Context, request *Request) (*Response, error) {
+	return startOrder(ctx, c.http, c.env, AuthEndpoint, request)
+}
+
+// Sign - see the package level Sign()
+func (c *Client) Sign(ctx context.Context, personalNumber string, userIP string, userVisible string, userNonVisible string) (*Response, error) {
+	return c.SignRequest(ctx, &Request{
+		PersonalNumber:     personalNumber,
+		EndUserIP:          userIP,
+		UserVisibleData:    userVisible,
+		UserNonVisibleData: userNonVisible,
+	})
+}
+
+// SignRequest - see the package level SignRequest()
+func (c *Client) SignRequest(ctx context.Context, request *Request) (*Response, error) {
+	return startOrder(ctx, c.http, c.env, SignEndpoint, request)
+}
+
+// Collect - see the package level Collect()
+func (c *Client) Collect(ctx context.Context, orderRef string) (*CollectResponse, error) {
+	return collect(ctx, c.http, c.env, orderRef)
+}
+
+// Cancel - see the package level Cancel()
+func (c *Client) Cancel(ctx context.Context, orderRef string) error {
+	return cancel(ctx, c.http, c.env, orderRef)
+}
+
+// CloseIdleConnections - closes pooled connections that are not in use
+func (c *Client) CloseIdleConnections() {
+	c.http.CloseIdleConnections()
+}
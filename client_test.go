@@ -0,0 +1,130 @@
+package bankid
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// tlsServer - a httptest TLS server counting new connections,
+// with an environment trusting its certificate
+type tlsServer struct {
+	server      *httptest.Server
+	env         *environment
+	connections int64
+	protoMajor  int64
+}
+
+func newTLSServer(http2 bool) *tlsServer {
+	s := &tlsServer{}
+	s.server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.StoreInt64(&s.protoMajor, int64(r.ProtoMajor))
+		json.NewEncoder(w).Encode(&CollectResponse{
+			OrderRef: "131daac9-16c6-4618-beb0-365768f37288",
+			Status:   OrderPending,
+			HintCode: PendUserSign,
+		})
+	}))
+	s.server.EnableHTTP2 = http2
+	s.server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&s.connections, 1)
+		}
+	}
+	s.server.StartTLS()
+
+	s.env = &environment{
+		baseURL:      s.server.URL,
+		apiVersion:   APIVersion,
+		clientConfig: s.server.Client().Transport.(*http.Transport).TLSClientConfig,
+	}
+	return s
+}
+
+func TestClientReusesConnections(t *testing.T) {
+	s := newTLSServer(false)
+	defer s.server.Close()
+
+	client := NewClient(s.env, nil)
+	for i := 0; i < 10; i++ {
+		rsp, err := client.Collect(context.Background(), "131daac9-16c6-4618-beb0-365768f37288")
+		assert.Nil(t, err)
+		assert.Equal(t, PendUserSign, rsp.HintCode)
+	}
+	assert.Equal(t, int64(1), atomic.LoadInt64(&s.connections))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&s.protoMajor))
+
+	// Package level calls handshake every time
+	for i := 0; i < 3; i++ {
+		_, err := Collect(s.env, "131daac9-16c6-4618-beb0-365768f37288")
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, int64(4), atomic.LoadInt64(&s.connections))
+}
+
+func TestClientHTTP2(t *testing.T) {
+	s := newTLSServer(true)
+	defer s.server.Close()
+
+	client := NewClient(s.env, &ClientConfig{HTTP2: true, MaxIdleConnsPerHost: 2})
+	_, err := client.Collect(context.Background(), "131daac9-16c6-4618-beb0-365768f37288")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&s.protoMajor))
+}
+
+func TestClientWithoutTLSConfig(t *testing.T) {
+	env := &testEnv{}
+	env.handler = func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&Response{OrderRef: "131daac9-16c6-4618-beb0-365768f37288"})
+	}
+
+	client := NewClient(env, nil)
+	ctx := context.Background()
+
+	rsp, err := client.Auth(ctx, "198001010001", "127.0.0.1")
+	assert.Nil(t, err)
+	assert.Equal(t, "131daac9-16c6-4618-beb0-365768f37288", rsp.OrderRef)
+
+	rsp, err = client.Sign(ctx, "198001010001", "127.0.0.1", "Hi User", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "131daac9-16c6-4618-beb0-365768f37288", rsp.OrderRef)
+
+	assert.Nil(t, client.Cancel(ctx, rsp.OrderRef))
+	client.CloseIdleConnections()
+	env.server.Close()
+}
+
+func BenchmarkCollectNewClientPerCall(b *testing.B) {
+	s := newTLSServer(false)
+	defer s.server.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Collect(s.env, "131daac9-16c6-4618-beb0-365768f37288"); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(atomic.LoadInt64(&s.connections))/float64(b.N), "conns/op")
+}
+
+func BenchmarkClientCollect(b *testing.B) {
+	s := newTLSServer(false)
+	defer s.server.Close()
+
+	client := NewClient(s.env, nil)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.Collect(ctx, "131daac9-16c6-4618-beb0-365768f37288"); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(atomic.LoadInt64(&s.connections))/float64(b.N), "conns/op")
+}
@@ -0,0 +1,95 @@
+package bankid
+
+// Verification of the completion data
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/onlyangel/bankid/signature"
+)
+
+// ErrSignatureUser - the signature is valid but the certificate is for someone else than the completion user
+var ErrSignatureUser = fmt.Errorf("%w: not by the completion user", signature.ErrInvalid)
+
+// ErrCertificateStatus - the OCSP response says the users certificate is revoked or unknown
+var ErrCertificateStatus = fmt.Errorf("%w: certificate not good", signature.ErrInvalid)
+
+// ErrDataMismatch - matches every DataMismatchError, use with errors.Is()
+var ErrDataMismatch = fmt.Errorf("%w: signed data mismatch", signature.ErrInvalid)
+
+// DataMismatchError - the signature doesn't cover what was sent to Sign, e.g a mixed up orderRef
+type DataMismatchError struct {
+	Field    string // "userVisibleData", "userNonVisibleData", "function" or "documents"
+	Expected []byte
+	Actual   []byte
+}
+
+// Error -
+func (e *DataMismatchError) Error() string {
+	return fmt.Sprintf("signed %s %q, expected %q", e.Field, e.Actual, e.Expected)
+}
+
+// Is - makes errors.Is(err, ErrDataMismatch) and errors.Is(err, signature.ErrInvalid) work
+func (e *DataMismatchError) Is(target error) bool {
+	return target == ErrDataMismatch || errors.Is(ErrDataMismatch, target)
+}
+
+// VerifySignature - verifies the XML signature, see signature.Verify(), and that the
+// users certificate is for the personal number in the completion data
+func (c *Completion) VerifySignature(opts *signature.Options) (*signature.Signature, error) {
+	sig, err := signature.Verify(c.Signature, opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not verify signature: %w", err)
+	}
+
+	if serial := sig.Certificate.Subject.SerialNumber; serial != c.User.PersonalNumber {
+		return nil, fmt.Errorf("%w: certificate is for %s, completion for %s", ErrSignatureUser, serial, c.User.PersonalNumber)
+	}
+	return sig, nil
+}
+
+// Verify - VerifySignature() and the OCSP response, see signature.VerifyOCSP(). The signing time of
+// the signature is when the OCSP response was produced. When the certificate is revoked or unknown
+// the results are returned with ErrCertificateStatus
+func (c *Completion) Verify(opts *signature.Options) (*signature.Signature, *signature.OCSP, error) {
+	sig, err := c.VerifySignature(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	status, err := sig.VerifyOCSP(c.OCSPResponse)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not verify OCSP response: %w", err)
+	}
+
+	if !status.Good() {
+		return sig, status, fmt.Errorf("%w: %s", ErrCertificateStatus, status.Status)
+	}
+	return sig, status, nil
+}
+
+// VerifySignedData - VerifySignature() for a complete sign order, and that the user signed exactly
+// userVisible and userNonVisible, as passed to Sign(). A *DataMismatchError if not
+func VerifySignedData(rsp *CollectResponse, userVisible string, userNonVisible string, opts *signature.Options) (*signature.Signature, error) {
+	if rsp == nil || rsp.Status != OrderComplete || rsp.CompletionData == nil {
+		return nil, errors.New("order is not complete")
+	}
+
+	sig, err := rsp.CompletionData.VerifySignature(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if sig.Function != signature.FunctionSigning {
+		return nil, &DataMismatchError{Field: "function", Expected: []byte(signature.FunctionSigning), Actual: []byte(sig.Function)}
+	}
+	if !bytes.Equal(sig.VisibleData, []byte(userVisible)) {
+		return nil, &DataMismatchError{Field: "userVisibleData", Expected: []byte(userVisible), Actual: sig.VisibleData}
+	}
+	if !bytes.Equal(sig.NonVisibleData, []byte(userNonVisible)) {
+		return nil, &DataMismatchError{Field: "userNonVisibleData", Expected: []byte(userNonVisible), Actual: sig.NonVisibleData}
+	}
+	return sig, nil
+}
@@ -0,0 +1,117 @@
+package bankid_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/onlyangel/bankid"
+	"github.com/onlyangel/bankid/bankidtest"
+	"github.com/onlyangel/bankid/signature"
+)
+
+// signed - a sign order for personalNumber, signed by the user on server and collected
+func signed(t *testing.T, server *bankidtest.Server, env bankid.Environmenter, personalNumber string, visible string, nonVisible string) *bankid.CollectResponse {
+	rsp, err := bankid.Sign(env, personalNumber, "127.0.0.1", visible, nonVisible)
+	if err != nil {
+		t.Fatalf("could not sign: %s", err.Error())
+	}
+	if err := server.Scan(rsp.OrderRef); err != nil {
+		t.Fatalf("could not scan: %s", err.Error())
+	}
+	if err := server.Sign(rsp.OrderRef); err != nil {
+		t.Fatalf("could not sign as the user: %s", err.Error())
+	}
+	collect, err := bankid.Collect(env, rsp.OrderRef)
+	if err != nil {
+		t.Fatalf("could not collect: %s", err.Error())
+	}
+	return collect
+}
+
+func testServer(t *testing.T) (*bankidtest.Server, bankid.Environmenter, *signature.Options) {
+	server := bankidtest.NewServer(nil)
+	t.Cleanup(server.Close)
+	env, err := server.Environment(bankid.APIVersionV6)
+	if err != nil {
+		t.Fatalf("could not create environment: %s", err.Error())
+	}
+	return server, env, &signature.Options{Roots: server.BankIDRoots()}
+}
+
+func TestVerifySignedDataMismatch(t *testing.T) {
+	server, env, opts := testServer(t)
+	collect := signed(t, server, env, "198001010001", "Pay 100 SEK to Kalle", "order=42")
+
+	sig, err := bankid.VerifySignedData(collect, "Pay 100 SEK to Kalle", "order=42", opts)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("Pay 100 SEK to Kalle"), sig.VisibleData)
+
+	var mismatch *bankid.DataMismatchError
+
+	_, err = bankid.VerifySignedData(collect, "Pay 1000 SEK to Kalle", "order=42", opts)
+	if assert.True(t, errors.As(err, &mismatch)) {
+		assert.Equal(t, "userVisibleData", mismatch.Field)
+		assert.Equal(t, []byte("Pay 1000 SEK to Kalle"), mismatch.Expected)
+		assert.Equal(t, []byte("Pay 100 SEK to Kalle"), mismatch.Actual)
+	}
+	assert.True(t, errors.Is(err, bankid.ErrDataMismatch))
+	assert.True(t, errors.Is(err, signature.ErrInvalid))
+
+	_, err = bankid.VerifySignedData(collect, "Pay 100 SEK to Kalle", "order=43", opts)
+	if assert.True(t, errors.As(err, &mismatch)) {
+		assert.Equal(t, "userNonVisibleData", mismatch.Field)
+		assert.Equal(t, []byte("order=43"), mismatch.Expected)
+		assert.Equal(t, []byte("order=42"), mismatch.Actual)
+	}
+
+	// The non-visible data is compared even when it was left out
+	_, err = bankid.VerifySignedData(collect, "Pay 100 SEK to Kalle", "", opts)
+	if assert.True(t, errors.As(err, &mismatch)) {
+		assert.Equal(t, "userNonVisibleData", mismatch.Field)
+	}
+}
+
+func TestVerifySignedDataSwapped(t *testing.T) {
+	server, env, opts := testServer(t)
+	first := signed(t, server, env, "198001010001", "Pay 100 SEK to Kalle", "order=42")
+	second := signed(t, server, env, "199001010108", "Pay 900 SEK to Olle", "order=43")
+
+	// A mixed up orderRef, the collect response of the other order
+	var mismatch *bankid.DataMismatchError
+	_, err := bankid.VerifySignedData(second, "Pay 100 SEK to Kalle", "order=42", opts)
+	if assert.True(t, errors.As(err, &mismatch)) {
+		assert.Equal(t, "userVisibleData", mismatch.Field)
+		assert.Equal(t, []byte("Pay 900 SEK to Olle"), mismatch.Actual)
+	}
+
+	// The whole completion data of the other order
+	swapped := *first
+	swapped.CompletionData = second.CompletionData
+	_, err = bankid.VerifySignedData(&swapped, "Pay 100 SEK to Kalle", "order=42", opts)
+	if assert.True(t, errors.As(err, &mismatch)) {
+		assert.Equal(t, "userVisibleData", mismatch.Field)
+	}
+
+	// Just the signature of the other order, it is not by the user
+	swapped = *first
+	completion := *first.CompletionData
+	completion.Signature = second.CompletionData.Signature
+	swapped.CompletionData = &completion
+	_, err = bankid.VerifySignedData(&swapped, "Pay 100 SEK to Kalle", "order=42", opts)
+	assert.True(t, errors.Is(err, bankid.ErrSignatureUser))
+	assert.False(t, errors.As(err, &mismatch))
+
+	// An auth order is not a signature of the data
+	rsp, err := bankid.Auth(env, "198001010001", "127.0.0.1")
+	assert.NoError(t, err)
+	assert.NoError(t, server.Scan(rsp.OrderRef))
+	assert.NoError(t, server.Sign(rsp.OrderRef))
+	auth, err := bankid.Collect(env, rsp.OrderRef)
+	assert.NoError(t, err)
+	_, err = bankid.VerifySignedData(auth, "", "", opts)
+	if assert.True(t, errors.As(err, &mismatch)) {
+		assert.Equal(t, "function", mismatch.Field)
+	}
+}
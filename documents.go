@@ -0,0 +1,239 @@
+package bankid
+
+// Signing documents by their SHA-256 digests
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/onlyangel/bankid/signature"
+)
+
+// ManifestVersion - version of the manifest format
+const ManifestVersion = 1
+
+// Document - a document to sign, Reader is read to the end once
+type Document struct {
+	Name   string
+	Reader io.Reader
+}
+
+// DocumentDigest - a signed document
+type DocumentDigest struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"` // Hex, lower case
+}
+
+// Manifest - the documents signed with SignDocuments(), store it with Canonical() to verify the signature later
+type Manifest struct {
+	Version   int              `json:"version"`
+	Language  string           `json:"language,omitempty"` // Of the Summary(), "sv" or empty for English
+	Documents []DocumentDigest `json:"documents"`          // Sorted by name
+}
+
+// summaryText - the words of a Summary() in one language
+type summaryText struct {
+	one  string // Heading for a single document
+	many string // Heading for several, with the count
+}
+
+// summaryTexts - what the user signs is rebuilt from the manifest to verify it, years later.
+// The texts are fixed here, a Catalog could change in between
+var summaryTexts = map[string]summaryText{
+	"":   {one: "You are signing 1 document:\n", many: "You are signing %d documents:\n"},
+	"sv": {one: "Du skriver under 1 dokument:\n", many: "Du skriver under %d dokument:\n"},
+}
+
+// NewManifest - reads and digests the documents. Names must be unique and may not contain control characters.
+// The Summary() is in English
+func NewManifest(documents ...Document) (*Manifest, error) {
+	return NewManifestIn("en", documents...)
+}
+
+// NewManifestIn - NewManifest() with the Summary() in lang, "en" or "sv" ("se" works too)
+func NewManifestIn(lang string, documents ...Document) (*Manifest, error) {
+	summaryLanguage, err := parseSummaryLanguage(lang)
+	if err != nil {
+		return nil, fmt.Errorf("could not create manifest: %s", err.Error())
+	}
+	if len(documents) == 0 {
+		return nil, errors.New("could not create manifest: no documents")
+	}
+
+	manifest := &Manifest{Version: ManifestVersion, Language: summaryLanguage}
+	seen := map[string]bool{}
+	for _, doc := range documents {
+		if err := validDocumentName(doc.Name); err != nil {
+			return nil, fmt.Errorf("could not create manifest: %s", err.Error())
+		}
+		if seen[doc.Name] {
+			return nil, fmt.Errorf("could not create manifest: duplicate document %q", doc.Name)
+		}
+		seen[doc.Name] = true
+
+		h := sha256.New()
+		size, err := io.Copy(h, doc.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("could not read document %q: %s", doc.Name, err.Error())
+		}
+		manifest.Documents = append(manifest.Documents, DocumentDigest{
+			Name:   doc.Name,
+			Size:   size,
+			SHA256: hex.EncodeToString(h.Sum(nil)),
+		})
+	}
+
+	sort.Slice(manifest.Documents, func(i, j int) bool {
+		return manifest.Documents[i].Name < manifest.Documents[j].Name
+	})
+	return manifest, nil
+}
+
+// ParseManifest - the inverse of Canonical(), anything but the canonical form is rejected
+func ParseManifest(data []byte) (*Manifest, error) {
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("could not parse manifest: %s", err.Error())
+	}
+	if manifest.Version != ManifestVersion {
+		return nil, fmt.Errorf("could not parse manifest: unsupported version %d", manifest.Version)
+	}
+	if _, ok := summaryTexts[manifest.Language]; !ok {
+		return nil, fmt.Errorf("could not parse manifest: unsupported language %q", manifest.Language)
+	}
+	if !bytes.Equal(manifest.Canonical(), data) {
+		return nil, errors.New("could not parse manifest: not in canonical form")
+	}
+	return manifest, nil
+}
+
+// parseSummaryLanguage - the Manifest.Language for lang, empty for English
+func parseSummaryLanguage(lang string) (string, error) {
+	tag, err := parseLanguage(lang)
+	if err != nil {
+		return "", fmt.Errorf("%s is not a supported language", lang)
+	}
+	base, _ := tag.Base()
+	switch base.String() {
+	case "en":
+		return "", nil
+	case "sv":
+		return "sv", nil
+	}
+	return "", fmt.Errorf("no document summary in %s, only en and sv", lang)
+}
+
+func validDocumentName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("document without name")
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("control character in document name %q", name)
+		}
+	}
+	return nil
+}
+
+// Canonical - the manifest as compact JSON, the userNonVisibleData of the sign order
+func (m *Manifest) Canonical() []byte {
+	// Struct fields marshal in order and the documents are sorted, a manifest has one encoding
+	data, _ := json.Marshal(m)
+	return data
+}
+
+// Summary - human readable list of the documents in the manifest language, the userVisibleData of the sign order
+func (m *Manifest) Summary() string {
+	text, ok := summaryTexts[m.Language]
+	if !ok {
+		text = summaryTexts[""]
+	}
+
+	var b strings.Builder
+	if len(m.Documents) == 1 {
+		b.WriteString(text.one)
+	} else {
+		fmt.Fprintf(&b, text.many, len(m.Documents))
+	}
+	for _, doc := range m.Documents {
+		fmt.Fprintf(&b, "\n%s\nSHA-256: %s\n", doc.Name, doc.SHA256)
+	}
+	return b.String()
+}
+
+// Verify - VerifySignedData() with the summary and the manifest
+func (m *Manifest) Verify(rsp *CollectResponse, opts *signature.Options) (*signature.Signature, error) {
+	return VerifySignedData(rsp, m.Summary(), string(m.Canonical()), opts)
+}
+
+// VerifyDocuments - checks that documents are exactly the signed ones, a *DataMismatchError if not
+func (m *Manifest) VerifyDocuments(documents ...Document) error {
+	other, err := NewManifest(documents...)
+	if err != nil {
+		return err
+	}
+	if expected, actual := m.Canonical(), other.Canonical(); !bytes.Equal(expected, actual) {
+		return &DataMismatchError{Field: "documents", Expected: expected, Actual: actual}
+	}
+	return nil
+}
+
+// SignDocuments - signs the SHA-256 digests of documents. The user sees the Summary() of
+// the manifest, in English, the Canonical() manifest is the non-visible data.
+// Use NewManifestIn() and SignManifest() for the summary in Swedish
+func SignDocuments(env Environmenter, personalNumber string, userIP string, documents ...Document) (*Response, *Manifest, error) {
+	return SignDocumentsContext(context.Background(), env, personalNumber, userIP, documents...)
+}
+
+// SignDocumentsContext - same as SignDocuments() but aborted when ctx is done
+func SignDocumentsContext(ctx context.Context, env Environmenter, personalNumber string, userIP string, documents ...Document) (*Response, *Manifest, error) {
+	manifest, err := NewManifest(documents...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rsp, err := SignManifestContext(ctx, env, personalNumber, userIP, manifest)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rsp, manifest, nil
+}
+
+// SignManifest - signs the documents of a manifest, e.g from NewManifestIn(). Same as SignDocuments() otherwise
+func SignManifest(env Environmenter, personalNumber string, userIP string, manifest *Manifest) (*Response, error) {
+	return SignManifestContext(context.Background(), env, personalNumber, userIP, manifest)
+}
+
+// SignManifestContext - same as SignManifest() but aborted when ctx is done
+func SignManifestContext(ctx context.Context, env Environmenter, personalNumber string, userIP string, manifest *Manifest) (*Response, error) {
+	return SignContext(ctx, env, personalNumber, userIP, manifest.Summary(), string(manifest.Canonical()))
+}
+
+// SignDocuments - see the package level SignDocuments()
+func (c *Client) SignDocuments(ctx context.Context, personalNumber string, userIP string, documents ...Document) (*Response, *Manifest, error) {
+	manifest, err := NewManifest(documents...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rsp, err := c.SignManifest(ctx, personalNumber, userIP, manifest)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rsp, manifest, nil
+}
+
+// SignManifest - see the package level SignManifest()
+func (c *Client) SignManifest(ctx context.Context, personalNumber string, userIP string, manifest *Manifest) (*Response, error) {
+	return c.Sign(ctx, personalNumber, userIP, manifest.Summary(), string(manifest.Canonical()))
+}
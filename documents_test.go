@@ -0,0 +1,87 @@
+package bankid
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewManifest(t *testing.T) {
+	manifest, err := NewManifest(
+		Document{Name: "terms.pdf", Reader: strings.NewReader("terms")},
+		Document{Name: "contract.pdf", Reader: strings.NewReader("")},
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, []DocumentDigest{
+		{Name: "contract.pdf", Size: 0, SHA256: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{Name: "terms.pdf", Size: 5, SHA256: "51d2361f4faea3bc8f9facdbc7d99abb555596a2e51f7b25fd3b41c93587e616"},
+	}, manifest.Documents)
+
+	canonical := `{"version":1,"documents":[` +
+		`{"name":"contract.pdf","size":0,"sha256":"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},` +
+		`{"name":"terms.pdf","size":5,"sha256":"51d2361f4faea3bc8f9facdbc7d99abb555596a2e51f7b25fd3b41c93587e616"}]}`
+	assert.Equal(t, canonical, string(manifest.Canonical()))
+	assert.True(t, strings.HasPrefix(manifest.Summary(), "You are signing 2 documents:\n\ncontract.pdf\nSHA-256: e3b0c442"))
+
+	parsed, err := ParseManifest([]byte(canonical))
+	assert.Nil(t, err)
+	assert.Equal(t, manifest, parsed)
+
+	_, err = ParseManifest([]byte(strings.Replace(canonical, ",", ", ", 1)))
+	assert.NotNil(t, err)
+
+	// The order of the documents doesn't matter
+	assert.Nil(t, manifest.VerifyDocuments(
+		Document{Name: "contract.pdf", Reader: strings.NewReader("")},
+		Document{Name: "terms.pdf", Reader: strings.NewReader("terms")},
+	))
+
+	err = manifest.VerifyDocuments(
+		Document{Name: "contract.pdf", Reader: strings.NewReader("")},
+		Document{Name: "terms.pdf", Reader: strings.NewReader("terms, changed")},
+	)
+	assert.True(t, errors.Is(err, ErrDataMismatch))
+}
+
+func TestNewManifestInvalid(t *testing.T) {
+	for name, documents := range map[string][]Document{
+		"no documents": nil,
+		"no name":      {{Name: " ", Reader: strings.NewReader("")}},
+		"newline":      {{Name: "a\nb.pdf", Reader: strings.NewReader("")}},
+		"duplicate":    {{Name: "a.pdf", Reader: strings.NewReader("")}, {Name: "a.pdf", Reader: strings.NewReader("")}},
+	} {
+		_, err := NewManifest(documents...)
+		assert.NotNil(t, err, name)
+	}
+}
+
+func TestNewManifestIn(t *testing.T) {
+	manifest, err := NewManifestIn("sv-SE", Document{Name: "avtal.pdf", Reader: strings.NewReader("")})
+	assert.Nil(t, err)
+	assert.Equal(t, "sv", manifest.Language)
+	assert.True(t, strings.HasPrefix(manifest.Summary(), "Du skriver under 1 dokument:\n\navtal.pdf\nSHA-256: e3b0c442"))
+
+	canonical := `{"version":1,"language":"sv","documents":[` +
+		`{"name":"avtal.pdf","size":0,"sha256":"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"}]}`
+	assert.Equal(t, canonical, string(manifest.Canonical()))
+
+	parsed, err := ParseManifest([]byte(canonical))
+	assert.Nil(t, err)
+	assert.Equal(t, manifest.Summary(), parsed.Summary())
+
+	se, err := NewManifestIn("se", Document{Name: "avtal.pdf", Reader: strings.NewReader("")})
+	assert.Nil(t, err)
+	assert.Equal(t, manifest, se)
+
+	en, err := NewManifestIn("en-GB", Document{Name: "avtal.pdf", Reader: strings.NewReader("")})
+	assert.Nil(t, err)
+	assert.Equal(t, "", en.Language)
+	assert.NotEqual(t, manifest.Summary(), en.Summary())
+
+	_, err = NewManifestIn("fi", Document{Name: "avtal.pdf", Reader: strings.NewReader("")})
+	assert.NotNil(t, err)
+	_, err = ParseManifest([]byte(strings.Replace(canonical, `"sv"`, `"fi"`, 1)))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,118 @@
+package bankid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Error codes, see ErrorResponse.ErrorCode
+const (
+	CodeAlreadyInProgress    = "alreadyInProgress"
+	CodeInvalidParameters    = "invalidParameters"
+	CodeUnauthorized         = "unauthorized"
+	CodeNotFound             = "notFound"
+	CodeMethodNotAllowed     = "methodNotAllowed"
+	CodeRequestTimeout       = "requestTimeout"
+	CodeUnsupportedMediaType = "unsupportedMediaType"
+	CodeInternalError        = "internalError"
+	CodeMaintenance          = "maintenance"
+)
+
+// Sentinel errors for every documented error code.
+// Use errors.Is(err, ErrAlreadyInProgress) instead of comparing ErrorResponse.ErrorCode
+var (
+	ErrAlreadyInProgress    = errors.New(CodeAlreadyInProgress)    // HTTP 400, an order for the personal number is already in progress
+	ErrInvalidParameters    = errors.New(CodeInvalidParameters)    // HTTP 400, see ErrorResponse.Details
+	ErrUnauthorized         = errors.New(CodeUnauthorized)         // HTTP 401, the RP does not have access to the service
+	ErrNotFound             = errors.New(CodeNotFound)             // HTTP 404, an erroneous URL path was used
+	ErrMethodNotAllowed     = errors.New(CodeMethodNotAllowed)     // HTTP 405, only POST is allowed
+	ErrRequestTimeout       = errors.New(CodeRequestTimeout)       // HTTP 408, the request took too long to send
+	ErrUnsupportedMediaType = errors.New(CodeUnsupportedMediaType) // HTTP 415, Content-Type must be application/json
+	ErrInternalError        = errors.New(CodeInternalError)        // HTTP 500, try again later
+	ErrMaintenance          = errors.New(CodeMaintenance)          // HTTP 503, try again later
+)
+
+var errorCodes = map[string]error{
+	CodeAlreadyInProgress:    ErrAlreadyInProgress,
+	CodeInvalidParameters:    ErrInvalidParameters,
+	CodeUnauthorized:         ErrUnauthorized,
+	CodeNotFound:             ErrNotFound,
+	CodeMethodNotAllowed:     ErrMethodNotAllowed,
+	CodeRequestTimeout:       ErrRequestTimeout,
+	CodeUnsupportedMediaType: ErrUnsupportedMediaType,
+	CodeInternalError:        ErrInternalError,
+	CodeMaintenance:          ErrMaintenance,
+}
+
+// TransportError - a failed response without a BankID error body,
+// e.g a HTML 503 page from a load balancer
+type TransportError struct {
+	StatusCode  int
+	ContentType string
+	Body        string // Truncated to the first 512 bytes
+	Err         error  // Why the body could not be parsed
+}
+
+// Error -
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("unexpected HTTP %d response (%s): %s", e.StatusCode, e.ContentType, e.Err.Error())
+}
+
+// Unwrap - the parse error
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// ErrAborted - matches every AbortedError, use with errors.Is()
+var ErrAborted = errors.New("request aborted")
+
+// AbortedError - a call that was cancelled or timed out before BankID answered.
+// Unwraps to the underlying cause, e.g context.Canceled or context.DeadlineExceeded
+type AbortedError struct {
+	Endpoint string
+	Timeout  bool // true for deadlines and client timeouts, false for cancellations
+	Err      error
+}
+
+// Error -
+func (e *AbortedError) Error() string {
+	reason := "cancelled"
+	if e.Timeout {
+		reason = "timed out"
+	}
+	return fmt.Sprintf("%s %s: %s", e.Endpoint, reason, e.Err.Error())
+}
+
+// Unwrap - the underlying cause
+func (e *AbortedError) Unwrap() error {
+	return e.Err
+}
+
+// Is - makes errors.Is(err, ErrAborted) work
+func (e *AbortedError) Is(target error) bool {
+	return target == ErrAborted
+}
+
+// abortedOr - turns context and timeout errors from http.Client.Do into an *AbortedError,
+// anything else is returned untouched
+func abortedOr(ctx context.Context, endpoint string, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return &AbortedError{
+			Endpoint: endpoint,
+			Timeout:  ctxErr == context.DeadlineExceeded,
+			Err:      ctxErr,
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return &AbortedError{
+			Endpoint: endpoint,
+			Timeout:  true,
+			Err:      err,
+		}
+	}
+	return err
+}
@@ -0,0 +1,68 @@
+package bankid
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorCodes(t *testing.T) {
+	for code, sentinel := range errorCodes {
+		status := http.StatusBadRequest
+		env := &testEnv{}
+		env.handler = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			fmt.Fprintf(w, `{"errorCode": "%s", "details": "Something went wrong"}`, code)
+		}
+
+		_, err := Auth(env, "198001010001", "127.0.0.1")
+		assert.True(t, errors.Is(err, sentinel), code)
+
+		var errRsp ErrorResponse
+		assert.True(t, errors.As(err, &errRsp))
+		assert.Equal(t, code, errRsp.ErrorCode)
+		assert.Equal(t, "Something went wrong", errRsp.Details)
+		assert.Equal(t, status, errRsp.StatusCode)
+
+		// Wrapped errors match too
+		assert.True(t, errors.Is(fmt.Errorf("login: %w", err), sentinel))
+		env.server.Close()
+	}
+}
+
+func TestErrorCodeMismatch(t *testing.T) {
+	err := ErrorResponse{ErrorCode: CodeAlreadyInProgress}
+	assert.True(t, errors.Is(err, ErrAlreadyInProgress))
+	assert.False(t, errors.Is(err, ErrInvalidParameters))
+
+	// Unknown codes match nothing
+	err = ErrorResponse{ErrorCode: "somethingNew"}
+	for _, sentinel := range errorCodes {
+		assert.False(t, errors.Is(err, sentinel))
+	}
+}
+
+func TestTransportError(t *testing.T) {
+	env := &testEnv{}
+	env.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("<html><body>Service Unavailable</body></html>"))
+	}
+
+	_, err := Collect(env, "dbbee61c-357b-4fd8-b103-392eed10be7a")
+	var transportErr *TransportError
+	assert.True(t, errors.As(err, &transportErr))
+	assert.Equal(t, http.StatusServiceUnavailable, transportErr.StatusCode)
+	assert.Equal(t, "text/html", transportErr.ContentType)
+	assert.Equal(t, "<html><body>Service Unavailable</body></html>", transportErr.Body)
+	assert.False(t, errors.Is(err, ErrMaintenance))
+
+	err = Cancel(env, "dbbee61c-357b-4fd8-b103-392eed10be7a")
+	assert.True(t, errors.As(err, &transportErr))
+	env.server.Close()
+}
@@ -0,0 +1,125 @@
+package bankid
+
+// Archivable proof of a completed sign order
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/onlyangel/bankid/signature"
+)
+
+// EvidenceVersion - version of the evidence format
+const EvidenceVersion = 1
+
+// ErrEvidence - the evidence contradicts itself
+var ErrEvidence = fmt.Errorf("%w: evidence", signature.ErrInvalid)
+
+// Evidence - everything needed to prove a sign order years later, without BankID.
+// The XML signature and the OCSP response are in the completion data
+type Evidence struct {
+	Version            int        `json:"version"`
+	OrderRef           string     `json:"orderRef"`
+	UserVisibleData    string     `json:"userVisibleData"`    // As passed to Sign(), not base64 encoded
+	UserNonVisibleData string     `json:"userNonVisibleData"` // As passed to Sign(), not base64 encoded
+	Manifest           *Manifest  `json:"manifest,omitempty"` // SignDocuments() only
+	Completion         Completion `json:"completionData"`
+	SignedAt           time.Time  `json:"signedAt"`  // When the OCSP response was produced
+	CreatedAt          time.Time  `json:"createdAt"` // When the evidence was created
+}
+
+// NewEvidence - verifies the signature and OCSP response of a complete sign order, as VerifySignedData()
+// and Completion.Verify(), and collects the evidence. A revoked certificate fails with ErrCertificateStatus
+func NewEvidence(rsp *CollectResponse, userVisible string, userNonVisible string, opts *signature.Options) (*Evidence, error) {
+	return newEvidence(rsp, userVisible, userNonVisible, nil, opts)
+}
+
+// Evidence - NewEvidence() for a SignDocuments() order, the manifest is included
+func (m *Manifest) Evidence(rsp *CollectResponse, opts *signature.Options) (*Evidence, error) {
+	return newEvidence(rsp, m.Summary(), string(m.Canonical()), m, opts)
+}
+
+func newEvidence(rsp *CollectResponse, userVisible string, userNonVisible string, manifest *Manifest, opts *signature.Options) (*Evidence, error) {
+	sig, err := VerifySignedData(rsp, userVisible, userNonVisible, opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not create evidence: %w", err)
+	}
+
+	status, err := sig.VerifyOCSP(rsp.CompletionData.OCSPResponse)
+	if err != nil {
+		return nil, fmt.Errorf("could not create evidence: %w", err)
+	}
+	if !status.Good() {
+		return nil, fmt.Errorf("could not create evidence: %w: %s", ErrCertificateStatus, status.Status)
+	}
+
+	return &Evidence{
+		Version:            EvidenceVersion,
+		OrderRef:           rsp.OrderRef,
+		UserVisibleData:    userVisible,
+		UserNonVisibleData: userNonVisible,
+		Manifest:           manifest,
+		Completion:         *rsp.CompletionData,
+		SignedAt:           status.ProducedAt,
+		CreatedAt:          time.Now().UTC(),
+	}, nil
+}
+
+// ParseEvidence - the inverse of Evidence.Marshal(), use VerifyEvidence() before trusting it
+func ParseEvidence(data []byte) (*Evidence, error) {
+	evidence := &Evidence{}
+	if err := json.Unmarshal(data, evidence); err != nil {
+		return nil, fmt.Errorf("could not parse evidence: %s", err.Error())
+	}
+	if evidence.Version != EvidenceVersion {
+		return nil, fmt.Errorf("could not parse evidence: unsupported version %d", evidence.Version)
+	}
+	return evidence, nil
+}
+
+// Marshal - the evidence as indented JSON, for archiving
+func (e *Evidence) Marshal() ([]byte, error) {
+	return json.MarshalIndent(e, "", "  ")
+}
+
+// VerifyEvidence - re-checks the evidence offline: the signature and the certificate chain as of
+// the signing time, that the OCSP response was good and produced at the signing time, that the
+// signature covers the sign inputs and that those are the manifest, if any
+func VerifyEvidence(evidence *Evidence, roots *x509.CertPool) (*signature.Signature, *signature.OCSP, error) {
+	if evidence == nil {
+		return nil, nil, errors.New("could not verify evidence: no evidence")
+	}
+
+	if m := evidence.Manifest; m != nil {
+		if evidence.UserVisibleData != m.Summary() {
+			return nil, nil, &DataMismatchError{Field: "userVisibleData", Expected: []byte(m.Summary()), Actual: []byte(evidence.UserVisibleData)}
+		}
+		if evidence.UserNonVisibleData != string(m.Canonical()) {
+			return nil, nil, &DataMismatchError{Field: "userNonVisibleData", Expected: m.Canonical(), Actual: []byte(evidence.UserNonVisibleData)}
+		}
+	}
+
+	// The certificates have most likely expired since, they must have been valid when the user signed
+	opts := &signature.Options{Roots: roots, Time: evidence.SignedAt}
+	rsp := &CollectResponse{OrderRef: evidence.OrderRef, Status: OrderComplete, CompletionData: &evidence.Completion}
+	sig, err := VerifySignedData(rsp, evidence.UserVisibleData, evidence.UserNonVisibleData, opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not verify evidence: %w", err)
+	}
+
+	status, err := sig.VerifyOCSP(evidence.Completion.OCSPResponse)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not verify evidence: %w", err)
+	}
+	if !status.ProducedAt.Equal(evidence.SignedAt) {
+		return nil, nil, fmt.Errorf("%w: signed at %s, OCSP response produced at %s", ErrEvidence,
+			evidence.SignedAt.Format(time.RFC3339), status.ProducedAt.Format(time.RFC3339))
+	}
+	if !status.Good() {
+		return nil, nil, fmt.Errorf("could not verify evidence: %w: %s", ErrCertificateStatus, status.Status)
+	}
+	return sig, status, nil
+}
@@ -0,0 +1,130 @@
+package bankid_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/onlyangel/bankid"
+	"github.com/onlyangel/bankid/signature"
+)
+
+func TestVerifyEvidenceTampered(t *testing.T) {
+	server, env, opts := testServer(t)
+	collect := signed(t, server, env, "198001010001", "Pay 100 SEK to Kalle", "order=42")
+	other := signed(t, server, env, "198001010001", "Pay 900 SEK to Olle", "order=43")
+
+	evidence, err := bankid.NewEvidence(collect, "Pay 100 SEK to Kalle", "order=42", opts)
+	assert.NoError(t, err)
+	archived, err := evidence.Marshal()
+	assert.NoError(t, err)
+
+	restored, err := bankid.ParseEvidence(archived)
+	assert.NoError(t, err)
+	_, status, err := bankid.VerifyEvidence(restored, server.BankIDRoots())
+	assert.NoError(t, err)
+	assert.Equal(t, signature.StatusGood, status.Status)
+
+	verify := func(change func(e *bankid.Evidence)) error {
+		e, err := bankid.ParseEvidence(archived)
+		assert.NoError(t, err)
+		change(e)
+		_, _, err = bankid.VerifyEvidence(e, server.BankIDRoots())
+		return err
+	}
+
+	for name, test := range map[string]struct {
+		change func(e *bankid.Evidence)
+		field  string
+	}{
+		"visible data":       {func(e *bankid.Evidence) { e.UserVisibleData = "Pay 1 SEK to Kalle" }, "userVisibleData"},
+		"non-visible data":   {func(e *bankid.Evidence) { e.UserNonVisibleData = "order=41" }, "userNonVisibleData"},
+		"swapped completion": {func(e *bankid.Evidence) { e.Completion = *other.CompletionData }, "userVisibleData"},
+	} {
+		err := verify(test.change)
+		var mismatch *bankid.DataMismatchError
+		if assert.True(t, errors.As(err, &mismatch), name) {
+			assert.Equal(t, test.field, mismatch.Field, name)
+		}
+		assert.True(t, errors.Is(err, signature.ErrInvalid), name)
+	}
+
+	err = verify(func(e *bankid.Evidence) { e.SignedAt = e.SignedAt.Add(time.Second) })
+	assert.True(t, errors.Is(err, bankid.ErrEvidence))
+
+	// A byte changed in the archived signature
+	e, _ := bankid.ParseEvidence(archived)
+	sig := []byte(e.Completion.Signature)
+	sig[len(sig)/2] ^= 1
+	err = verify(func(e *bankid.Evidence) { e.Completion.Signature = string(sig) })
+	assert.True(t, errors.Is(err, signature.ErrInvalid))
+}
+
+func TestVerifyEvidenceManifest(t *testing.T) {
+	server, env, opts := testServer(t)
+
+	contract := "%PDF-1.7 contract"
+	rsp, manifest, err := bankid.SignDocuments(env, "198001010001", "127.0.0.1",
+		bankid.Document{Name: "contract.pdf", Reader: strings.NewReader(contract)})
+	assert.NoError(t, err)
+	assert.NoError(t, server.Scan(rsp.OrderRef))
+	assert.NoError(t, server.Sign(rsp.OrderRef))
+	collect, err := bankid.Collect(env, rsp.OrderRef)
+	assert.NoError(t, err)
+
+	evidence, err := manifest.Evidence(collect, opts)
+	assert.NoError(t, err)
+	archived, err := evidence.Marshal()
+	assert.NoError(t, err)
+
+	// The manifest says another document was signed than the signature does
+	e, err := bankid.ParseEvidence(archived)
+	assert.NoError(t, err)
+	e.Manifest.Documents[0].SHA256 = strings.Repeat("0", 64)
+	_, _, err = bankid.VerifyEvidence(e, server.BankIDRoots())
+	var mismatch *bankid.DataMismatchError
+	if assert.True(t, errors.As(err, &mismatch)) {
+		assert.Equal(t, "userVisibleData", mismatch.Field) // The summary shows the digest
+	}
+
+	// The sign inputs follow the manifest, still not what was signed
+	e.UserVisibleData = e.Manifest.Summary()
+	e.UserNonVisibleData = string(e.Manifest.Canonical())
+	_, _, err = bankid.VerifyEvidence(e, server.BankIDRoots())
+	if assert.True(t, errors.As(err, &mismatch)) {
+		assert.Equal(t, "userVisibleData", mismatch.Field)
+		assert.Equal(t, []byte(manifest.Summary()), mismatch.Actual)
+	}
+}
+
+func TestVerifyManifestLanguage(t *testing.T) {
+	server, env, opts := testServer(t)
+
+	contract := "%PDF-1.7 avtal"
+	manifest, err := bankid.NewManifestIn("sv", bankid.Document{Name: "avtal.pdf", Reader: strings.NewReader(contract)})
+	assert.NoError(t, err)
+	rsp, err := bankid.SignManifest(env, "198001010001", "127.0.0.1", manifest)
+	assert.NoError(t, err)
+	assert.NoError(t, server.Scan(rsp.OrderRef))
+	assert.NoError(t, server.Sign(rsp.OrderRef))
+	collect, err := bankid.Collect(env, rsp.OrderRef)
+	assert.NoError(t, err)
+
+	parsed, err := bankid.ParseManifest(manifest.Canonical())
+	assert.NoError(t, err)
+	sig, err := parsed.Verify(collect, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte(manifest.Summary()), sig.VisibleData)
+
+	// The same documents, but not the text the user saw
+	english, err := bankid.NewManifest(bankid.Document{Name: "avtal.pdf", Reader: strings.NewReader(contract)})
+	assert.NoError(t, err)
+	_, err = english.Verify(collect, opts)
+	var mismatch *bankid.DataMismatchError
+	if assert.True(t, errors.As(err, &mismatch)) {
+		assert.Equal(t, "userVisibleData", mismatch.Field)
+	}
+}
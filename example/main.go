@@ -22,13 +22,13 @@ func main() {
 
 	// bankid.TestBaseURL or bankid.ProductionBaseURL
 	//env, err := bankid.NewEnvironment(bankid.TestBaseURL, caTestPath, rpCrtPath, rpKeyPath)
-	env, err := bankid.NewEnvironmentP12(bankid.TestBaseURL, caTestPath, rpP12Path)
+	env, err := bankid.NewEnvironmentP12(bankid.TestBaseURL, caTestPath, rpP12Path, "qwerty123") // NOTE: Replace with your p12 password
 	if err != nil {
 		log.Printf(" !! Could not create TestEnvironment: %s", err.Error())
 		os.Exit(1)
 	}
 
-	// Remove non-digits from personal number
+	// YYYYMMDDNNNN, YYMMDD-NNNN and the other common forms are validated and normalized for you
 	personalNumber := "200107091241" // NOTE: Replace with a real personal number
 	ipAddr := "2806:2f0:51c1:abd4:2429:96a4:1ec6:ae82"            // IP of your mobile phone with BankID app on it
 
@@ -37,7 +37,8 @@ func main() {
 
 	rsp, err := bankid.Auth(env, personalNumber, ipAddr)
 	if err != nil {
-		log.Printf(" !! Could not connect to server: %s\n", err.Error())
+		_, msg := p.ForError(err)
+		log.Printf(" !! Could not connect to server: %s (%s)\n", err.Error(), msg)
 		os.Exit(1)
 	}
 
@@ -52,54 +53,18 @@ func main() {
 			os.Exit(1)
 		}
 
+		// The message for the hint code as the RP guidelines say, pass a *bankid.MessageContext for autostart and mobiles
+		if _, msg := p.ForCollect(collectResponse, nil); msg != "" {
+			fmt.Println(" >> " + msg)
+		}
+
 		switch collectResponse.Status {
-		case bankid.OrderPending:
-			switch collectResponse.HintCode {
-			case bankid.PendOutstandingTransaction:
-				{
-					fmt.Println(" >> " + p.Msg(bankid.RFA1))
-				}
-			case bankid.PendNoClient:
-				{
-					fmt.Println(" >> " + p.Msg(bankid.RFA1))
-				}
-			case bankid.PendStarted:
-				{
-					fmt.Println(" >> " + p.Msg(bankid.RFA14_B))
-				}
-			case bankid.PendUserSign:
-				{
-					fmt.Println(" >> " + p.Msg(bankid.RFA9))
-				}
-			}
 		case bankid.OrderFailed:
-			{
-				done = true
-				switch collectResponse.HintCode {
-				case bankid.FailCancelled:
-					{
-						fmt.Println(" >> " + p.Msg(bankid.RFA3))
-						break
-					}
-				case bankid.FailUserCancel:
-					{
-						fmt.Println(" >> " + p.Msg(bankid.RFA6))
-						break
-					}
-				case bankid.FailExpiredTransaction:
-					{
-						fmt.Println(" >> " + p.Msg(bankid.RFA8))
-						break
-					}
-				}
-			}
+			done = true
 		case bankid.OrderComplete:
-			{
-				done = true
-				log.Println(" >> 😎 Auth Complete ")
-				log.Printf(" >> %s signed in!\n", collectResponse.CompletionData.User.Name)
-				break
-			}
+			done = true
+			log.Println(" >> 😎 Auth Complete ")
+			log.Printf(" >> %s signed in!\n", collectResponse.CompletionData.User.Name)
 		}
 		// Don't spam the service plz
 		time.Sleep(2 * time.Second)
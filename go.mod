@@ -1,9 +1,20 @@
 module github.com/onlyangel/bankid
 
-go 1.12
+go 1.19
 
 require (
-	github.com/stretchr/testify v1.4.0
-	golang.org/x/crypto v0.0.0-20200820211705-5c72a883971a // indirect
-	software.sslmate.com/src/go-pkcs12 v0.0.0-20200830195227-52f69702a001
+	github.com/beevik/etree v1.1.0
+	github.com/russellhaering/goxmldsig v1.4.0
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
+	github.com/stretchr/testify v1.6.1
+	golang.org/x/crypto v0.11.0
+	golang.org/x/text v0.13.0
+	software.sslmate.com/src/go-pkcs12 v0.7.3
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/jonboulle/clockwork v0.2.2 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
 )
@@ -0,0 +1,110 @@
+package signtest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"time"
+)
+
+// NewKey - a P-256 key
+func NewKey() (*ecdsa.PrivateKey, error) {
+	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+}
+
+// Template - a certificate for commonName with a random serial number, valid from an hour ago for a day.
+// CAs may sign certificates and CRLs
+func Template(commonName string, isCA bool) *x509.Certificate {
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	t := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if isCA {
+		t.KeyUsage |= x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	}
+	return t
+}
+
+// Issue - the certificate of template for key, signed by parent and parentKey. Self-signed when parent is nil
+func Issue(template *x509.Certificate, key crypto.Signer, parent *x509.Certificate, parentKey crypto.Signer) (*x509.Certificate, error) {
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+// Chain - a BankID root CA and a bank CA under it, that issues the users certificates
+type Chain struct {
+	Root    *x509.Certificate
+	RootKey crypto.Signer
+	Bank    *x509.Certificate
+	BankKey crypto.Signer
+	Roots   *x509.CertPool // Just the root, for signature.Options
+}
+
+// NewChain - a root and bank CA with names starting with name
+func NewChain(name string) (*Chain, error) {
+	rootKey, err := NewKey()
+	if err != nil {
+		return nil, err
+	}
+	root, err := Issue(Template(name+" BankID Root CA v1", true), rootKey, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	bankKey, err := NewKey()
+	if err != nil {
+		return nil, err
+	}
+	bank, err := Issue(Template(name+" Customer CA1 v1 for BankID", true), bankKey, root, rootKey)
+	if err != nil {
+		return nil, err
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	return &Chain{Root: root, RootKey: rootKey, Bank: bank, BankKey: bankKey, Roots: roots}, nil
+}
+
+// User - a users certificate for key issued by the bank CA, e.g from Template(name, false).
+// Returns the chain as in a signature: user, bank and root
+func (c *Chain) User(template *x509.Certificate, key crypto.Signer) ([]*x509.Certificate, error) {
+	template.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment
+	cert, err := Issue(template, key, c.Bank, c.BankKey)
+	if err != nil {
+		return nil, err
+	}
+	return []*x509.Certificate{cert, c.Bank, c.Root}, nil
+}
+
+// Responder - a delegated OCSP responder of the bank CA, with usage as its only extended key usage
+func (c *Chain) Responder(usage x509.ExtKeyUsage) (*x509.Certificate, crypto.Signer, error) {
+	key, err := NewKey()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := Template("OCSP Responder", false)
+	template.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+	cert, err := Issue(template, key, c.Bank, c.BankKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
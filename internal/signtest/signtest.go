@@ -0,0 +1,147 @@
+// Package signtest - signatures in the BankID format, for the fake server and tests of this module.
+// BankID creates the real ones, so this is kept out of the public signature package
+package signtest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/russellhaering/goxmldsig/etreeutils"
+
+	"github.com/onlyangel/bankid/signature"
+)
+
+var excC14N = string(dsig.CanonicalXML10ExclusiveAlgorithmId)
+
+// Create - a signature in the BankID format over data, by the first certificate in
+// chain and its key. Returns the XML, base64 encode it for the completion data
+func Create(data *signature.Data, chain []*x509.Certificate, key crypto.Signer) ([]byte, error) {
+	if len(chain) == 0 {
+		return nil, errors.New("could not create signature: no certificate")
+	}
+
+	var algorithm string
+	switch key.Public().(type) {
+	case *rsa.PublicKey:
+		algorithm = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	case *ecdsa.PublicKey:
+		algorithm = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256"
+	default:
+		return nil, fmt.Errorf("could not create signature: unsupported key %T", key.Public())
+	}
+
+	doc := etree.NewDocument()
+	doc.CreateProcInst("xml", `version="1.0" encoding="UTF-8" standalone="no"`)
+
+	root := doc.CreateElement("Signature")
+	root.CreateAttr("xmlns", signature.NamespaceDSig)
+
+	signedInfo := root.CreateElement("SignedInfo")
+	signedInfo.CreateAttr("xmlns", signature.NamespaceDSig)
+	signedInfo.CreateElement("CanonicalizationMethod").CreateAttr("Algorithm", excC14N)
+	signedInfo.CreateElement("SignatureMethod").CreateAttr("Algorithm", algorithm)
+
+	signatureValue := root.CreateElement("SignatureValue")
+
+	keyInfo := root.CreateElement("KeyInfo")
+	keyInfo.CreateAttr("xmlns", signature.NamespaceDSig)
+	keyInfo.CreateAttr("Id", signature.KeyInfoID)
+	x509Data := keyInfo.CreateElement("X509Data")
+	for _, cert := range chain {
+		x509Data.CreateElement("X509Certificate").SetText(base64.StdEncoding.EncodeToString(cert.Raw))
+	}
+
+	signedData := root.CreateElement("Object").CreateElement("bankIdSignedData")
+	signedData.CreateAttr("xmlns", signature.NamespaceBankID)
+	signedData.CreateAttr("Id", signature.SignedDataID)
+	if len(data.VisibleData) > 0 {
+		el := signedData.CreateElement("usrVisibleData")
+		el.CreateAttr("charset", "UTF-8")
+		el.CreateAttr("visible", "wysiwys")
+		el.SetText(base64.StdEncoding.EncodeToString(data.VisibleData))
+	}
+	if len(data.NonVisibleData) > 0 {
+		signedData.CreateElement("usrNonVisibleData").SetText(base64.StdEncoding.EncodeToString(data.NonVisibleData))
+	}
+	srvInfo := signedData.CreateElement("srvInfo")
+	srvInfo.CreateElement("name").SetText(base64.StdEncoding.EncodeToString([]byte(data.RPName)))
+	srvInfo.CreateElement("nonce").SetText(data.Nonce)
+	srvInfo.CreateElement("displayName").SetText(base64.StdEncoding.EncodeToString([]byte(data.RPDisplayName)))
+	signedData.CreateElement("clientInfo").CreateElement("funcId").SetText(data.Function)
+
+	for _, target := range []*etree.Element{signedData, keyInfo} {
+		canonical, err := canonicalize(target)
+		if err != nil {
+			return nil, fmt.Errorf("could not create signature: %s", err.Error())
+		}
+		digest := sha256.Sum256(canonical)
+
+		ref := signedInfo.CreateElement("Reference")
+		if target == signedData {
+			ref.CreateAttr("Type", signature.NamespaceBankID)
+		}
+		ref.CreateAttr("URI", "#"+target.SelectAttrValue("Id", ""))
+		ref.CreateElement("Transforms").CreateElement("Transform").CreateAttr("Algorithm", excC14N)
+		ref.CreateElement("DigestMethod").CreateAttr("Algorithm", "http://www.w3.org/2001/04/xmlenc#sha256")
+		ref.CreateElement("DigestValue").SetText(base64.StdEncoding.EncodeToString(digest[:]))
+	}
+
+	canonical, err := canonicalize(signedInfo)
+	if err != nil {
+		return nil, fmt.Errorf("could not create signature: %s", err.Error())
+	}
+	digest := sha256.Sum256(canonical)
+
+	value, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("could not create signature: %s", err.Error())
+	}
+	if pub, ok := key.Public().(*ecdsa.PublicKey); ok {
+		if value, err = rawECDSA(value, pub); err != nil {
+			return nil, fmt.Errorf("could not create signature: %s", err.Error())
+		}
+	}
+	signatureValue.SetText(base64.StdEncoding.EncodeToString(value))
+
+	return doc.WriteToBytes()
+}
+
+// canonicalize - exclusive XML canonicalization of el, with the namespaces in scope from its ancestors
+func canonicalize(el *etree.Element) ([]byte, error) {
+	ctx, err := etreeutils.NSBuildParentContext(el)
+	if err != nil {
+		return nil, err
+	}
+
+	detached, err := etreeutils.NSDetatch(ctx, el)
+	if err != nil {
+		return nil, err
+	}
+
+	return dsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("").Canonicalize(detached)
+}
+
+// rawECDSA - ASN.1 ECDSA signature to the r || s form XMLDSig uses
+func rawECDSA(der []byte, pub *ecdsa.PublicKey) ([]byte, error) {
+	var sig struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, err
+	}
+
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	raw := make([]byte, 2*size)
+	sig.R.FillBytes(raw[:size])
+	sig.S.FillBytes(raw[size:])
+	return raw, nil
+}
@@ -0,0 +1,374 @@
+package bankid
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrOrderExpired - sent to subscribers of orders evicted after ManagerConfig.TTL
+var ErrOrderExpired = errors.New("order expired")
+
+// ErrManagerClosed - returned by an OrderManager after Close()
+var ErrManagerClosed = errors.New("order manager closed")
+
+// ErrOrderNotTracked - returned when subscribing to an unknown or evicted order
+var ErrOrderNotTracked = errors.New("order not tracked")
+
+// ManagerConfig - optional settings for an OrderManager, zero values use the defaults
+type ManagerConfig struct {
+	Workers  int           // Concurrent collect calls, default 8
+	Interval time.Duration // Time between collect calls per order, default PollInterval
+	Jitter   time.Duration // Random extra delay per collect, spreads the load. Default Interval / 4
+	TTL      time.Duration // Orders still pending after this long are evicted, default 5 minutes
+	Buffer   int           // Updates buffered per subscriber, default 4
+}
+
+// OrderUpdate - sent to subscribers on every change of status or hint code,
+// and when a collect fails
+type OrderUpdate struct {
+	OrderRef string
+	Response *CollectResponse // The latest response, nil until the first successful collect
+	Err      error            // Set when collect failed or the order was evicted
+}
+
+// OrderManager - polls many orders from a bounded pool of workers.
+// Orders are evicted when they are complete, failed, rejected by BankID or older than the TTL.
+// Subscribers get an update for every change and their channel is closed on eviction.
+type OrderManager struct {
+	collect collectFunc
+	cfg     ManagerConfig
+
+	mu      sync.Mutex
+	orders  map[string]*trackedOrder
+	queue   orderQueue
+	rand    *rand.Rand
+	closed  bool
+	wake    chan struct{}
+	jobs    chan *trackedOrder
+	ctx     context.Context
+	stop    context.CancelFunc
+	workers sync.WaitGroup
+}
+
+type trackedOrder struct {
+	orderRef    string
+	added       time.Time
+	next        time.Time
+	last        *CollectResponse
+	subscribers map[chan OrderUpdate]struct{}
+}
+
+// NewOrderManager - starts the workers polling orders through client, cfg may be nil.
+// Call Close() when done.
+func NewOrderManager(client *Client, cfg *ManagerConfig) *OrderManager {
+	return newOrderManager(client.Collect, cfg)
+}
+
+func newOrderManager(collectFn collectFunc, cfg *ManagerConfig) *OrderManager {
+	c := ManagerConfig{}
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.Workers <= 0 {
+		c.Workers = 8
+	}
+	if c.Interval <= 0 {
+		c.Interval = PollInterval
+	}
+	if c.Jitter <= 0 {
+		c.Jitter = c.Interval / 4
+	}
+	if c.TTL <= 0 {
+		c.TTL = 5 * time.Minute
+	}
+	if c.Buffer <= 0 {
+		c.Buffer = 4
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	m := &OrderManager{
+		collect: collectFn,
+		cfg:     c,
+		orders:  map[string]*trackedOrder{},
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		wake:    make(chan struct{}, 1),
+		jobs:    make(chan *trackedOrder),
+		ctx:     ctx,
+		stop:    stop,
+	}
+
+	m.workers.Add(c.Workers + 1)
+	go m.schedule()
+	for i := 0; i < c.Workers; i++ {
+		go m.work()
+	}
+	return m
+}
+
+// Track - start polling orderRef, tracking an order twice is a no-op
+func (m *OrderManager) Track(orderRef string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrManagerClosed
+	}
+	if _, ok := m.orders[orderRef]; ok {
+		return nil
+	}
+
+	now := time.Now()
+	order := &trackedOrder{
+		orderRef:    orderRef,
+		added:       now,
+		next:        now.Add(m.jitter()),
+		subscribers: map[chan OrderUpdate]struct{}{},
+	}
+	m.orders[orderRef] = order
+	heap.Push(&m.queue, order)
+	m.poke()
+	return nil
+}
+
+// Subscribe - updates for a tracked order. The latest known state, if any, is sent right away.
+// The channel is closed when the order is evicted, call the returned func to unsubscribe early.
+func (m *OrderManager) Subscribe(orderRef string) (<-chan OrderUpdate, func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, nil, ErrManagerClosed
+	}
+	order, ok := m.orders[orderRef]
+	if !ok {
+		return nil, nil, ErrOrderNotTracked
+	}
+
+	ch := make(chan OrderUpdate, m.cfg.Buffer)
+	order.subscribers[ch] = struct{}{}
+	if order.last != nil {
+		ch <- OrderUpdate{OrderRef: orderRef, Response: order.last}
+	}
+
+	unsubscribe := func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if _, ok := order.subscribers[ch]; ok {
+			delete(order.subscribers, ch)
+			close(ch)
+		}
+	}
+	return ch, unsubscribe, nil
+}
+
+// Forget - stop polling orderRef and close its subscriptions
+func (m *OrderManager) Forget(orderRef string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if order, ok := m.orders[orderRef]; ok {
+		m.evict(order)
+	}
+}
+
+// Len - number of tracked orders
+func (m *OrderManager) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.orders)
+}
+
+// Close - stops the workers, aborts collects in flight and closes every subscription
+func (m *OrderManager) Close() {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return
+	}
+	m.closed = true
+	m.stop()
+	m.mu.Unlock()
+
+	m.workers.Wait()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, order := range m.orders {
+		m.evict(order)
+	}
+}
+
+// schedule - hands due orders to the workers, earliest first
+func (m *OrderManager) schedule() {
+	defer m.workers.Done()
+	defer close(m.jobs)
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		m.mu.Lock()
+		var due *trackedOrder
+		wait := time.Hour
+		for len(m.queue) > 0 {
+			next := m.queue[0]
+			if m.orders[next.orderRef] != next {
+				heap.Pop(&m.queue) // Evicted while queued
+				continue
+			}
+			if wait = time.Until(next.next); wait <= 0 {
+				due = heap.Pop(&m.queue).(*trackedOrder)
+			}
+			break
+		}
+		m.mu.Unlock()
+
+		if due != nil {
+			select {
+			case m.jobs <- due:
+				continue
+			case <-m.ctx.Done():
+				return
+			}
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-m.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// work - collects due orders until the scheduler stops
+func (m *OrderManager) work() {
+	defer m.workers.Done()
+
+	for order := range m.jobs {
+		rsp, err := m.collect(m.ctx, order.orderRef)
+		m.update(order, rsp, err)
+	}
+}
+
+func (m *OrderManager) update(order *trackedOrder, rsp *CollectResponse, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.orders[order.orderRef] != order {
+		return // Forgotten while collecting
+	}
+
+	if err != nil {
+		if m.ctx.Err() != nil {
+			return // Closing
+		}
+		m.publish(order, OrderUpdate{OrderRef: order.orderRef, Response: order.last, Err: err})
+		if permanent(err) {
+			m.evict(order)
+			return
+		}
+	} else {
+		if order.last == nil || order.last.Status != rsp.Status || order.last.HintCode != rsp.HintCode {
+			m.publish(order, OrderUpdate{OrderRef: order.orderRef, Response: rsp})
+		}
+		order.last = rsp
+
+		if rsp.Status == OrderComplete || rsp.Status == OrderFailed {
+			m.evict(order)
+			return
+		}
+	}
+
+	now := time.Now()
+	if now.Sub(order.added) > m.cfg.TTL {
+		m.publish(order, OrderUpdate{OrderRef: order.orderRef, Response: order.last, Err: ErrOrderExpired})
+		m.evict(order)
+		return
+	}
+
+	order.next = now.Add(m.cfg.Interval + m.jitter())
+	heap.Push(&m.queue, order)
+	m.poke()
+}
+
+// permanent - errors that won't go away by collecting again
+func permanent(err error) bool {
+	var errRsp ErrorResponse
+	if !errors.As(err, &errRsp) {
+		return false // Transport errors and timeouts
+	}
+	return !errors.Is(err, ErrInternalError) && !errors.Is(err, ErrMaintenance) && !errors.Is(err, ErrRequestTimeout)
+}
+
+// publish - never blocks, slow subscribers lose their oldest update. Call with m.mu held
+func (m *OrderManager) publish(order *trackedOrder, update OrderUpdate) {
+	for ch := range order.subscribers {
+		select {
+		case ch <- update:
+			continue
+		default:
+		}
+
+		select {
+		case <-ch:
+		default:
+		}
+		select {
+		case ch <- update:
+		default:
+		}
+	}
+}
+
+// evict - call with m.mu held
+func (m *OrderManager) evict(order *trackedOrder) {
+	delete(m.orders, order.orderRef)
+	for ch := range order.subscribers {
+		delete(order.subscribers, ch)
+		close(ch)
+	}
+}
+
+// jitter - call with m.mu held
+func (m *OrderManager) jitter() time.Duration {
+	if m.cfg.Jitter <= 0 {
+		return 0
+	}
+	return time.Duration(m.rand.Int63n(int64(m.cfg.Jitter)))
+}
+
+// poke - wake the scheduler up, call with m.mu held
+func (m *OrderManager) poke() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// orderQueue - min-heap on the next collect time
+type orderQueue []*trackedOrder
+
+func (q orderQueue) Len() int            { return len(q) }
+func (q orderQueue) Less(i, j int) bool  { return q[i].next.Before(q[j].next) }
+func (q orderQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *orderQueue) Push(x interface{}) { *q = append(*q, x.(*trackedOrder)) }
+func (q *orderQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,243 @@
+package bankid
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockEnv - like testEnv but with one server for every request, safe for concurrent use
+type mockEnv struct {
+	server *httptest.Server
+}
+
+func newMockEnv(handler http.HandlerFunc) *mockEnv {
+	return &mockEnv{server: httptest.NewServer(handler)}
+}
+
+func (m *mockEnv) NewClient() *http.Client {
+	return m.server.Client()
+}
+
+func (m *mockEnv) NewRequest(ctx context.Context, endpoint string, body interface{}) (*http.Request, error) {
+	requestBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", m.server.URL+APIVersion+endpoint, strings.NewReader(string(requestBody)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "Application/json")
+	return req, nil
+}
+
+// orderScripts - collect answers per orderRef, the last one repeats
+type orderScripts struct {
+	mu       sync.Mutex
+	scripts  map[string][]*CollectResponse
+	collects map[string]int
+	inFlight int64
+	maxSeen  int64
+}
+
+func (o *orderScripts) handler(w http.ResponseWriter, r *http.Request) {
+	current := atomic.AddInt64(&o.inFlight, 1)
+	defer atomic.AddInt64(&o.inFlight, -1)
+	for {
+		seen := atomic.LoadInt64(&o.maxSeen)
+		if current <= seen || atomic.CompareAndSwapInt64(&o.maxSeen, seen, current) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond) // Let the calls overlap
+
+	request := Request{}
+	json.NewDecoder(r.Body).Decode(&request)
+
+	o.mu.Lock()
+	script, ok := o.scripts[request.OrderRef]
+	n := o.collects[request.OrderRef]
+	o.collects[request.OrderRef]++
+	o.mu.Unlock()
+
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"errorCode": "notFound", "details": "No such order"}`))
+		return
+	}
+	if n >= len(script) {
+		n = len(script) - 1
+	}
+	json.NewEncoder(w).Encode(script[n])
+}
+
+func newOrderScripts() *orderScripts {
+	return &orderScripts{
+		scripts:  map[string][]*CollectResponse{},
+		collects: map[string]int{},
+	}
+}
+
+func drain(ch <-chan OrderUpdate) []OrderUpdate {
+	updates := []OrderUpdate{}
+	for update := range ch {
+		updates = append(updates, update)
+	}
+	return updates
+}
+
+func TestOrderManagerManyOrders(t *testing.T) {
+	scripts := newOrderScripts()
+	for i := 0; i < 50; i++ {
+		orderRef := fmt.Sprintf("order-%d", i)
+		scripts.scripts[orderRef] = []*CollectResponse{
+			{OrderRef: orderRef, Status: OrderPending, HintCode: PendOutstandingTransaction},
+			{OrderRef: orderRef, Status: OrderPending, HintCode: PendUserSign},
+			{OrderRef: orderRef, Status: OrderComplete, CompletionData: &Completion{User: User{Name: orderRef}}},
+		}
+	}
+	env := newMockEnv(scripts.handler)
+	defer env.server.Close()
+
+	m := NewOrderManager(NewClient(env, nil), &ManagerConfig{Workers: 4, Interval: 5 * time.Millisecond})
+	defer m.Close()
+
+	wg := sync.WaitGroup{}
+	for orderRef := range scripts.scripts {
+		assert.Nil(t, m.Track(orderRef))
+		assert.Nil(t, m.Track(orderRef)) // No-op
+		updates, _, err := m.Subscribe(orderRef)
+		assert.Nil(t, err)
+
+		wg.Add(1)
+		go func(orderRef string) {
+			defer wg.Done()
+			received := drain(updates)
+			assert.Equal(t, 3, len(received), orderRef)
+			assert.Equal(t, PendOutstandingTransaction, received[0].Response.HintCode)
+			assert.Equal(t, PendUserSign, received[1].Response.HintCode)
+			assert.Equal(t, OrderComplete, received[2].Response.Status)
+			assert.Equal(t, orderRef, received[2].Response.CompletionData.User.Name)
+		}(orderRef)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 0, m.Len())
+	assert.True(t, atomic.LoadInt64(&scripts.maxSeen) <= 4)
+	for orderRef, collects := range scripts.collects {
+		assert.Equal(t, 3, collects, orderRef)
+	}
+}
+
+func TestOrderManagerFanOut(t *testing.T) {
+	scripts := newOrderScripts()
+	scripts.scripts["order"] = []*CollectResponse{
+		{OrderRef: "order", Status: OrderPending, HintCode: PendUserSign},
+		{OrderRef: "order", Status: OrderFailed, HintCode: FailUserCancel},
+	}
+	env := newMockEnv(scripts.handler)
+	defer env.server.Close()
+
+	m := NewOrderManager(NewClient(env, nil), &ManagerConfig{Interval: 5 * time.Millisecond})
+	defer m.Close()
+
+	assert.Nil(t, m.Track("order"))
+	first, _, err := m.Subscribe("order")
+	assert.Nil(t, err)
+	second, _, err := m.Subscribe("order")
+	assert.Nil(t, err)
+
+	for _, updates := range []<-chan OrderUpdate{first, second} {
+		received := drain(updates)
+		assert.Equal(t, 2, len(received))
+		assert.Equal(t, FailUserCancel, received[1].Response.HintCode)
+	}
+
+	// Evicted
+	_, _, err = m.Subscribe("order")
+	assert.True(t, errors.Is(err, ErrOrderNotTracked))
+}
+
+func TestOrderManagerEviction(t *testing.T) {
+	scripts := newOrderScripts()
+	scripts.scripts["forever"] = []*CollectResponse{
+		{OrderRef: "forever", Status: OrderPending, HintCode: PendOutstandingTransaction},
+	}
+	env := newMockEnv(scripts.handler)
+	defer env.server.Close()
+
+	m := NewOrderManager(NewClient(env, nil), &ManagerConfig{Interval: 5 * time.Millisecond, TTL: 50 * time.Millisecond})
+	defer m.Close()
+
+	// Pending for too long
+	assert.Nil(t, m.Track("forever"))
+	updates, _, err := m.Subscribe("forever")
+	assert.Nil(t, err)
+	received := drain(updates)
+	last := received[len(received)-1]
+	assert.True(t, errors.Is(last.Err, ErrOrderExpired))
+	assert.Equal(t, PendOutstandingTransaction, last.Response.HintCode)
+
+	// Rejected by BankID
+	assert.Nil(t, m.Track("unknown"))
+	updates, _, err = m.Subscribe("unknown")
+	assert.Nil(t, err)
+	received = drain(updates)
+	assert.Equal(t, 1, len(received))
+	assert.True(t, errors.Is(received[0].Err, ErrNotFound))
+	assert.Equal(t, 0, m.Len())
+}
+
+func TestOrderManagerForgetAndClose(t *testing.T) {
+	scripts := newOrderScripts()
+	scripts.scripts["order"] = []*CollectResponse{
+		{OrderRef: "order", Status: OrderPending, HintCode: PendOutstandingTransaction},
+	}
+	env := newMockEnv(scripts.handler)
+	defer env.server.Close()
+
+	m := NewOrderManager(NewClient(env, nil), &ManagerConfig{Interval: 5 * time.Millisecond})
+
+	_, _, err := m.Subscribe("order")
+	assert.True(t, errors.Is(err, ErrOrderNotTracked))
+
+	// Forget
+	assert.Nil(t, m.Track("order"))
+	updates, _, err := m.Subscribe("order")
+	assert.Nil(t, err)
+	m.Forget("order")
+	drain(updates)
+	assert.Equal(t, 0, m.Len())
+
+	// Unsubscribe
+	assert.Nil(t, m.Track("order"))
+	updates, unsubscribe, err := m.Subscribe("order")
+	assert.Nil(t, err)
+	unsubscribe()
+	unsubscribe()
+	drain(updates)
+
+	// Close
+	updates, _, err = m.Subscribe("order")
+	assert.Nil(t, err)
+	m.Close()
+	m.Close()
+	drain(updates)
+	assert.Equal(t, 0, m.Len())
+
+	assert.True(t, errors.Is(m.Track("order"), ErrManagerClosed))
+	_, _, err = m.Subscribe("order")
+	assert.True(t, errors.Is(err, ErrManagerClosed))
+}
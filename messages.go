@@ -3,8 +3,7 @@ package bankid
 // Very simple i18n language mapping
 
 import (
-	"fmt"
-	"strings"
+	"golang.org/x/text/language"
 )
 
 // Messages
@@ -32,6 +31,12 @@ const (
 	RFA22   = "RFA22"
 )
 
+// RFAKeys - every message key, in order
+var RFAKeys = []string{
+	RFA1, RFA2, RFA3, RFA4, RFA5, RFA6, RFA8, RFA9, RFA13, RFA14_A, RFA14_B, RFA15_A, RFA15_B,
+	RFA16, RFA17_A, RFA17_B, RFA18, RFA19, RFA20, RFA21, RFA22,
+}
+
 // Messages in Swedish
 var messages_SE = map[string]string{
 	RFA1:    "Starta BankID-appen",
@@ -82,31 +87,41 @@ var messages_EN = map[string]string{
 	RFA22:   "Unknown error. Please try again.",
 }
 
+// defaultCatalog - the official messages, never modified
+var defaultCatalog = NewCatalog()
+
 // Messages - keep track of the user facing messages for the language we choose
 type Messages struct {
-	msgs map[string]string
+	lang   language.Tag
+	chain  []map[string]string // The language first, then its fallbacks
+	params Params              // Default placeholder values, see WithParams()
 }
 
-// NewMessages - instance with messages in the provided language
+// NewMessages - instance with messages in the provided language, "sv" or "en".
+// "se" is accepted for Swedish as well. Missing keys fall back to English
 func NewMessages(lang string) (*Messages, error) {
-	var messages map[string]string = nil
+	return defaultCatalog.Lookup(lang)
+}
 
-	switch strings.ToLower(lang) {
-	case "se":
-		messages = messages_SE
-	case "en":
-		messages = messages_EN
-	default:
-		return nil, fmt.Errorf("%s it not a supported language", lang)
-	}
+// NegotiateMessages - the official messages in the best language for a HTTP Accept-Language header,
+// English if neither Swedish nor English is acceptable. Use a Catalog for more languages
+func NegotiateMessages(acceptLanguage string) *Messages {
+	return defaultCatalog.Negotiate(acceptLanguage)
+}
 
-	return &Messages{
-		msgs: messages,
-	}, nil
+// Language - the language of the messages
+func (m *Messages) Language() language.Tag {
+	return m.lang
 }
 
-// Msg - pick out the messages string for the provided key
-// Note: No error handling here, missing keys will return an empty string
+// Msg - pick out the messages string for the provided key, from the fallback languages if it's missing.
+// Placeholders are not filled in, see Text(), HTML() and Markdown() for that
+// Note: No error handling here, keys missing in every language will return an empty string
 func (m *Messages) Msg(key string) string {
-	return m.msgs[key]
+	for _, msgs := range m.chain {
+		if msg := msgs[key]; msg != "" {
+			return msg
+		}
+	}
+	return ""
 }
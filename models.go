@@ -26,24 +26,73 @@ const (
 	// FailUnknown
 )
 
+// Who called who, for phone/auth and phone/sign
+const (
+	CallInitiatorUser = "user"
+	CallInitiatorRP   = "RP"
+)
+
+// User visible data formats
+const (
+	FormatPlaintext      = "plaintext"
+	FormatSimpleMarkdown = "simpleMarkdownV1"
+)
+
 type Request struct {
 	OrderRef           string `json:"orderRef,omitempty"`
 	EndUserIP          string `json:"endUserIp,omitempty"`
-	PersonalNumber     string `json:"personalNumber,omitempty"`
+	PersonalNumber     string `json:"personalNumber,omitempty"` // v5 only, moved into Requirement in v6
 	UserVisibleData    string `json:"userVisibleData,omitempty"`
 	UserNonVisibleData string `json:"userNonVisibleData,omitempty"`
+
+	// v6 only
+	UserVisibleDataFormat string       `json:"userVisibleDataFormat,omitempty"`
+	ReturnURL             string       `json:"returnUrl,omitempty"`
+	ReturnRisk            bool         `json:"returnRisk,omitempty"`
+	Requirement           *Requirement `json:"requirement,omitempty"`
+	CallInitiator         string       `json:"callInitiator,omitempty"` // phone/auth and phone/sign only, CallInitiatorUser or CallInitiatorRP
+}
+
+// Requirement - optional conditions the user and the order must fulfill
+type Requirement struct {
+	PersonalNumber      string   `json:"personalNumber,omitempty"` // v6 only
+	PinCode             bool     `json:"pinCode,omitempty"`
+	Mrtd                bool     `json:"mrtd,omitempty"` // v6 only
+	CardReader          string   `json:"cardReader,omitempty"`
+	CertificatePolicies []string `json:"certificatePolicies,omitempty"`
+}
+
+// forVersion - returns a copy of the request shaped for the given API version
+func (r Request) forVersion(version string) Request {
+	if version != APIVersionV6 || r.PersonalNumber == "" {
+		return r
+	}
+
+	requirement := Requirement{}
+	if r.Requirement != nil {
+		requirement = *r.Requirement
+	}
+	if requirement.PersonalNumber == "" {
+		requirement.PersonalNumber = r.PersonalNumber
+	}
+	r.Requirement = &requirement
+	r.PersonalNumber = ""
+	return r
 }
 
 // Response - for Auth and Sign requests
 type Response struct {
-	AutoStartToken string `json:"autoStartToken"` // UUID, e.g "dbbee61c-357b-4fd8-b103-392eed10be7a"
-	OrderRef       string `json:"orderRef"`       // UUID, e.g "131daac9-16c6-4618-beb0-365768f37288"
+	AutoStartToken string `json:"autoStartToken"`          // UUID, e.g "dbbee61c-357b-4fd8-b103-392eed10be7a"
+	OrderRef       string `json:"orderRef"`                // UUID, e.g "131daac9-16c6-4618-beb0-365768f37288"
+	QRStartToken   string `json:"qrStartToken,omitempty"`  // UUID, used to generate animated QR codes
+	QRStartSecret  string `json:"qrStartSecret,omitempty"` // UUID, keep it on the server side
 }
 
 // ErrorResponse - when anything goes bad
 type ErrorResponse struct {
-	ErrorCode string `json:"errorCode"`
-	Details   string `json:"details"`
+	ErrorCode  string `json:"errorCode"`
+	Details    string `json:"details"`
+	StatusCode int    `json:"-"` // HTTP status code of the response
 }
 
 // Error -
@@ -51,6 +100,12 @@ func (e ErrorResponse) Error() string {
 	return fmt.Sprintf("failed with code: %s. '%s'", e.ErrorCode, e.Details)
 }
 
+// Is - makes errors.Is(err, ErrAlreadyInProgress) and friends work
+func (e ErrorResponse) Is(target error) bool {
+	sentinel, ok := errorCodes[e.ErrorCode]
+	return ok && sentinel == target
+}
+
 type CollectResponse struct {
 	OrderRef       string      `json:"orderRef"`
 	Status         string      `json:"status"`
@@ -61,20 +116,31 @@ type CollectResponse struct {
 type Completion struct {
 	User         User   `json:"user"`
 	Device       Device `json:"device"`
-	Cert         Cert   `json:"cert"`
+	Cert         Cert   `json:"cert"`                // v5 only
 	Signature    string `json:"signature,omitempty"` // base64 encoded signature, see https://www.bankid.com/bankid-i-dina-tjanster/rp-info
 	OCSPResponse string `json:"ocspResponse,omitempty"`
+
+	// v6 only
+	BankIDIssueDate string  `json:"bankIdIssueDate,omitempty"` // e.g "2020-02-01"
+	StepUp          *StepUp `json:"stepUp,omitempty"`
+	Risk            string  `json:"risk,omitempty"` // "low", "moderate" or "high", only when ReturnRisk was requested
 }
 
 type User struct {
-	PersonalNumber string `json:"personalNumber"` // e.g "197001010000"
+	PersonalNumber string `json:"personalNumber"` // e.g "197001010003"
 	Name           string `json:"name"`
-	GivenName      string `json:"giveName"`
+	GivenName      string `json:"givenName"`
 	Surname        string `json:"surname"`
 }
 
 type Device struct {
-	IPAddress string `json:"ipAddress"` // e.g "192.168.0.1"
+	IPAddress string `json:"ipAddress"`     // e.g "192.168.0.1"
+	UHI       string `json:"uhi,omitempty"` // v6 only, unique hardware identifier
+}
+
+// StepUp - v6 only, additional checks performed during the order
+type StepUp struct {
+	Mrtd bool `json:"mrtd"` // true if a MRTD check was performed and passed
 }
 
 type Cert struct {
@@ -0,0 +1,281 @@
+package bankid
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Option - configures an environment created by New()
+type Option func(*config)
+
+type config struct {
+	baseURL     string
+	apiVersion  string
+	ca          []byte
+	rpCerts     []tls.Certificate
+	timeout     time.Duration
+	dialTimeout time.Duration
+	proxy       func(*http.Request) (*url.URL, error)
+	httpClient  *http.Client
+	caFailed    bool // A CA option failed, it is already reported
+	errs        []error
+}
+
+func (c *config) fail(err error) {
+	c.errs = append(c.errs, err)
+}
+
+// ConfigError - every mistake found by New(), not just the first one
+type ConfigError struct {
+	Errs []error
+}
+
+// Error -
+func (e *ConfigError) Error() string {
+	msgs := make([]string, 0, len(e.Errs))
+	for _, err := range e.Errs {
+		msgs = append(msgs, err.Error())
+	}
+	return "invalid environment: " + strings.Join(msgs, "; ")
+}
+
+// Is - errors.Is() for any of the mistakes. Go 1.19 does not follow a multi error Unwrap() []error
+func (e *ConfigError) Is(target error) bool {
+	for _, err := range e.Errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As - errors.As() for the first of the mistakes that matches
+func (e *ConfigError) As(target interface{}) bool {
+	for _, err := range e.Errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// New - an environment for the BankID API, e.g
+//
+//	bankid.New(
+//		bankid.WithBaseURL(bankid.TestBaseURL),
+//		bankid.WithP12File("rp.p12", password),
+//		bankid.WithTimeout(5*time.Second),
+//	)
+//
+// The official base URLs get the matching embedded CA certificate unless WithCA() is used.
+// All configuration mistakes are reported at once in a *ConfigError.
+func New(opts ...Option) (Environmenter, error) {
+	cfg := &config{
+		apiVersion:  APIVersion,
+		timeout:     10 * time.Second,
+		dialTimeout: 5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if cfg.baseURL == "" {
+		cfg.fail(errors.New("a base URL is required, see WithBaseURL()"))
+	}
+
+	if cfg.apiVersion != APIVersionV5 && cfg.apiVersion != APIVersionV6 {
+		cfg.fail(fmt.Errorf("%s is not a supported API version", cfg.apiVersion))
+	}
+
+	if cfg.ca == nil {
+		switch cfg.baseURL {
+		case TestBaseURL:
+			cfg.ca = caTest
+		case ProductionBaseURL:
+			cfg.ca = caProduction
+		}
+	}
+
+	if cfg.timeout <= 0 || cfg.dialTimeout <= 0 {
+		cfg.fail(errors.New("timeouts must be positive"))
+	}
+
+	env := &environment{
+		baseURL:     cfg.baseURL,
+		apiVersion:  cfg.apiVersion,
+		timeout:     cfg.timeout,
+		dialTimeout: cfg.dialTimeout,
+		proxy:       cfg.proxy,
+		httpClient:  cfg.httpClient,
+	}
+
+	// A custom http.Client brings its own TLS setup
+	if cfg.httpClient != nil && len(cfg.rpCerts) > 0 {
+		cfg.fail(errors.New("RP certificates can't be combined with WithHTTPClient()"))
+	}
+
+	if cfg.httpClient == nil {
+		if len(cfg.rpCerts) != 1 {
+			cfg.fail(fmt.Errorf("exactly one RP certificate is required, got %d", len(cfg.rpCerts)))
+		}
+
+		caPool, err := certPool(cfg.ca)
+		if err != nil && !cfg.caFailed {
+			cfg.fail(err)
+		}
+
+		env.clientConfig = &tls.Config{
+			Certificates: cfg.rpCerts,
+			ClientCAs:    caPool,
+			RootCAs:      caPool,
+			// InsecureSkipVerify: true, // For some reason is BankID not using a proper domain certificate
+		}
+	}
+
+	if len(cfg.errs) > 0 {
+		return nil, &ConfigError{Errs: cfg.errs}
+	}
+	return env, nil
+}
+
+// WithBaseURL - ProductionBaseURL, TestBaseURL or your own mock
+func WithBaseURL(baseURL string) Option {
+	return func(c *config) {
+		c.baseURL = baseURL
+	}
+}
+
+// WithAPIVersion - APIVersionV5 or APIVersionV6, default APIVersion
+func WithAPIVersion(version string) Option {
+	return func(c *config) {
+		c.apiVersion = version
+	}
+}
+
+// WithCA - PEM encoded CA certificate of the BankID server, see CACertificate()
+func WithCA(caPEM []byte) Option {
+	return func(c *config) {
+		c.ca = caPEM
+	}
+}
+
+// WithCAFile - same as WithCA() but read from a file
+func WithCAFile(caPath string) Option {
+	return func(c *config) {
+		ca, err := ioutil.ReadFile(caPath)
+		if err != nil {
+			c.fail(fmt.Errorf("could not load CA Certificate: %s", err.Error()))
+			c.caFailed = true
+			return
+		}
+		c.ca = ca
+	}
+}
+
+// WithCertificate - the RP certificate and key
+func WithCertificate(rpCert tls.Certificate) Option {
+	return func(c *config) {
+		c.rpCerts = append(c.rpCerts, rpCert)
+	}
+}
+
+// WithKeyPairPEM - the PEM encoded RP certificate and key
+func WithKeyPairPEM(rpCertPEM []byte, rpKeyPEM []byte) Option {
+	return func(c *config) {
+		rpCert, err := tls.X509KeyPair(rpCertPEM, rpKeyPEM)
+		if err != nil {
+			c.fail(fmt.Errorf("could not load RP Keypair: %s", err.Error()))
+			return
+		}
+		c.rpCerts = append(c.rpCerts, rpCert)
+	}
+}
+
+// WithKeyPairFiles - same as WithKeyPairPEM() but read from files
+func WithKeyPairFiles(rpCertPath string, rpKeyPath string) Option {
+	return func(c *config) {
+		rpCert, err := tls.LoadX509KeyPair(rpCertPath, rpKeyPath)
+		if err != nil {
+			c.fail(fmt.Errorf("could not load RP Keypair: %s", err.Error()))
+			return
+		}
+		c.rpCerts = append(c.rpCerts, rpCert)
+	}
+}
+
+// WithP12 - the RP certificate, key and intermediate chain in a PKCS#12 bag
+func WithP12(p12Data []byte, password string) Option {
+	return func(c *config) {
+		rpCert, err := loadP12(p12Data, password)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		c.rpCerts = append(c.rpCerts, rpCert)
+	}
+}
+
+// WithP12File - same as WithP12() but read from a file
+func WithP12File(p12Path string, password string) Option {
+	return func(c *config) {
+		p12Data, err := ioutil.ReadFile(p12Path)
+		if err != nil {
+			c.fail(fmt.Errorf("could not load p12 certificate: %s", err.Error()))
+			return
+		}
+		WithP12(p12Data, password)(c)
+	}
+}
+
+// WithTimeout - limit for a whole call to BankID, default 10s
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.timeout = timeout
+	}
+}
+
+// WithDialTimeout - limit for opening a connection to BankID, default 5s
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.dialTimeout = timeout
+	}
+}
+
+// WithProxy - proxy selection, default none, not even HTTPS_PROXY. Use http.ProxyFromEnvironment
+// to follow the environment, or http.ProxyURL() for a fixed proxy
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) Option {
+	return func(c *config) {
+		c.proxy = proxy
+	}
+}
+
+// WithHTTPClient - use your own http.Client, it must present the RP certificate itself.
+// Certificates, timeouts and proxies from the other options are not applied to it.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *config) {
+		if client == nil {
+			c.fail(errors.New("the http.Client must not be nil"))
+			return
+		}
+		c.httpClient = client
+	}
+}
+
+func certPool(caPEM []byte) (*x509.CertPool, error) {
+	if caPEM == nil {
+		return nil, errors.New("a CA certificate is required, see WithCA()")
+	}
+
+	caPool := x509.NewCertPool()
+	if caPool.AppendCertsFromPEM(caPEM) == false {
+		return nil, fmt.Errorf("could not append CA Certificate to pool. Invalid certificate?")
+	}
+	return caPool, nil
+}
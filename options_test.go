@@ -0,0 +1,157 @@
+package bankid
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAllMistakesAtOnce(t *testing.T) {
+	_, err := New(
+		WithAPIVersion("/rp/v4"),
+		WithCAFile("INVALID_CA"),
+		WithKeyPairFiles("INVALID_RP_CERT", "INVALID_RP_KEY"),
+		WithP12File("INVALID_P12", "qwerty123"),
+		WithTimeout(-time.Second),
+		WithHTTPClient(nil),
+	)
+
+	var cfgErr *ConfigError
+	assert.True(t, errors.As(err, &cfgErr))
+	assert.Equal(t, 8, len(cfgErr.Errs), err.Error())
+	for _, msg := range []string{
+		"could not load CA Certificate",
+		"could not load RP Keypair",
+		"could not load p12 certificate",
+		"a base URL is required",
+		"/rp/v4 is not a supported API version",
+		"timeouts must be positive",
+		"the http.Client must not be nil",
+	} {
+		assert.Contains(t, err.Error(), msg)
+	}
+	// The failed WithCAFile() is the only CA mistake reported
+	assert.NotContains(t, err.Error(), "a CA certificate is required")
+
+	// Nothing at all
+	_, err = New()
+	assert.True(t, errors.As(err, &cfgErr))
+	assert.Contains(t, err.Error(), "exactly one RP certificate is required, got 0")
+}
+
+type optionError struct{ option string }
+
+func (e *optionError) Error() string { return e.option + " failed" }
+
+func TestConfigErrorIsAs(t *testing.T) {
+	errSentinel := errors.New("sentinel")
+	_, err := New(
+		WithBaseURL(TestBaseURL),
+		func(c *config) { c.fail(fmt.Errorf("wrapped: %w", errSentinel)) },
+		func(c *config) { c.fail(&optionError{option: "custom"}) },
+	)
+
+	assert.True(t, errors.Is(err, errSentinel))
+	assert.False(t, errors.Is(err, os.ErrNotExist))
+
+	var optErr *optionError
+	if assert.True(t, errors.As(err, &optErr)) {
+		assert.Equal(t, "custom", optErr.option)
+	}
+	var cfgErr *ConfigError
+	assert.True(t, errors.As(err, &cfgErr))
+}
+
+func TestNewDefaults(t *testing.T) {
+	env, err := New(
+		WithBaseURL(TestBaseURL), // Embedded test CA
+		WithKeyPairFiles("./rp/bankid_rp_test.crt", "./rp/bankid_rp_test.key"),
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, APIVersion, apiVersion(env))
+
+	client := env.NewClient()
+	assert.Equal(t, 10*time.Second, client.Timeout)
+
+	// Custom endpoints need a CA
+	_, err = New(
+		WithBaseURL("https://bankid.mock.local"),
+		WithKeyPairFiles("./rp/bankid_rp_test.crt", "./rp/bankid_rp_test.key"),
+	)
+	assert.NotNil(t, err)
+}
+
+func TestNewOptions(t *testing.T) {
+	rpCert, err := tls.LoadX509KeyPair("./rp/bankid_rp_test.crt", "./rp/bankid_rp_test.key")
+	assert.Nil(t, err)
+	proxyURL, _ := url.Parse("http://proxy.local:3128")
+
+	ca, _ := os.ReadFile("./CA/production.crt")
+	env, err := New(
+		WithBaseURL(ProductionBaseURL),
+		WithAPIVersion(APIVersionV6),
+		WithCA(ca),
+		WithCertificate(rpCert),
+		WithTimeout(3*time.Second),
+		WithDialTimeout(time.Second),
+		WithProxy(http.ProxyURL(proxyURL)),
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, APIVersionV6, apiVersion(env))
+
+	client := env.NewClient()
+	assert.Equal(t, 3*time.Second, client.Timeout)
+
+	req, err := env.NewRequest(context.Background(), AuthEndpoint, "")
+	assert.Nil(t, err)
+	proxy, err := client.Transport.(*http.Transport).Proxy(req)
+	assert.Nil(t, err)
+	assert.Equal(t, proxyURL, proxy)
+
+	// Two certificates
+	_, err = New(WithBaseURL(ProductionBaseURL), WithCertificate(rpCert), WithCertificate(rpCert))
+	assert.NotNil(t, err)
+}
+
+func TestNoProxyByDefault(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "http://proxy.local:3128")
+
+	env, err := NewEnvironment(TestBaseURL, "./CA/test.crt", "./rp/bankid_rp_test.crt", "./rp/bankid_rp_test.key")
+	assert.Nil(t, err)
+	assert.Nil(t, env.NewClient().Transport.(*http.Transport).Proxy)
+	assert.Nil(t, NewClient(env, nil).http.Transport.(*http.Transport).Proxy)
+
+	env, err = New(
+		WithBaseURL(TestBaseURL),
+		WithKeyPairFiles("./rp/bankid_rp_test.crt", "./rp/bankid_rp_test.key"),
+		WithProxy(http.ProxyFromEnvironment),
+	)
+	assert.Nil(t, err)
+	assert.NotNil(t, env.NewClient().Transport.(*http.Transport).Proxy)
+}
+
+func TestNewWithHTTPClient(t *testing.T) {
+	httpClient := &http.Client{Timeout: time.Second}
+
+	env, err := New(WithBaseURL(TestBaseURL), WithHTTPClient(httpClient))
+	assert.Nil(t, err)
+	assert.Equal(t, httpClient, env.NewClient())
+	assert.Nil(t, env.(TLSConfigurer).TLSConfig())
+	assert.Equal(t, httpClient, NewClient(env, nil).http)
+
+	// The client brings its own certificate
+	_, err = New(
+		WithBaseURL(TestBaseURL),
+		WithHTTPClient(httpClient),
+		WithKeyPairFiles("./rp/bankid_rp_test.crt", "./rp/bankid_rp_test.key"),
+	)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,85 @@
+package bankid
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+
+	pkcs12 "software.sslmate.com/src/go-pkcs12"
+)
+
+// PasswordFunc - returns the PKCS#12 password, e.g from a secret manager
+type PasswordFunc func() (string, error)
+
+// loadP12 - the RP certificate, its key and the intermediate chain from a PKCS#12 bag.
+// Handles both legacy (RC2/3DES) and modern (AES/PBKDF2) encryption.
+func loadP12(p12Data []byte, password string) (tls.Certificate, error) {
+	key, first, rest, err := pkcs12.DecodeChain(p12Data, password)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("could not decode p12 certificate: %s", err.Error())
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return tls.Certificate{}, fmt.Errorf("unsupported p12 private key type %T", key)
+	}
+
+	// The leaf is the certificate belonging to the key, not necessarily the first one in the bag
+	var leaf *x509.Certificate
+	others := []*x509.Certificate{}
+	for _, cert := range append([]*x509.Certificate{first}, rest...) {
+		if leaf == nil && publicKeyMatches(cert, signer.Public()) {
+			leaf = cert
+			continue
+		}
+		others = append(others, cert)
+	}
+	if leaf == nil {
+		return tls.Certificate{}, fmt.Errorf("no certificate in the p12 bag matches its private key")
+	}
+
+	rpCert := tls.Certificate{
+		Certificate: [][]byte{leaf.Raw},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+	for _, cert := range chain(leaf, others) {
+		rpCert.Certificate = append(rpCert.Certificate, cert.Raw)
+	}
+	return rpCert, nil
+}
+
+// publicKeyMatches - true if cert was issued for the public key
+func publicKeyMatches(cert *x509.Certificate, public crypto.PublicKey) bool {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(cert.RawSubjectPublicKeyInfo, der)
+}
+
+// chain - the intermediates from leaf upwards, stops at self-signed roots and unrelated certificates
+func chain(leaf *x509.Certificate, pool []*x509.Certificate) []*x509.Certificate {
+	result := []*x509.Certificate{}
+	used := map[*x509.Certificate]bool{}
+
+	current := leaf
+	for {
+		var issuer *x509.Certificate
+		for _, cert := range pool {
+			if !used[cert] && bytes.Equal(current.RawIssuer, cert.RawSubject) && current.CheckSignatureFrom(cert) == nil {
+				issuer = cert
+				break
+			}
+		}
+		if issuer == nil || bytes.Equal(issuer.RawIssuer, issuer.RawSubject) {
+			return result // Roots don't belong in the chain we present
+		}
+
+		used[issuer] = true
+		result = append(result, issuer)
+		current = issuer
+	}
+}
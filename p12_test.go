@@ -0,0 +1,89 @@
+package bankid
+
+import (
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/onlyangel/bankid/internal/signtest"
+	"github.com/stretchr/testify/assert"
+	pkcs12 "software.sslmate.com/src/go-pkcs12"
+)
+
+// testCertificate - a certificate for name signed by parent, self-signed when parent is nil
+func testCertificate(t *testing.T, name string, isCA bool, parent *x509.Certificate, parentKey crypto.Signer) (*x509.Certificate, crypto.Signer) {
+	key, err := signtest.NewKey()
+	assert.Nil(t, err)
+
+	template := signtest.Template(name, isCA)
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth}
+	cert, err := signtest.Issue(template, key, parent, parentKey)
+	assert.Nil(t, err)
+	return cert, key
+}
+
+func TestP12TestCertificate(t *testing.T) {
+	_, err := NewEnvironmentP12(TestBaseURL, "./CA/test.crt", "./rp/test.p12", "qwerty123")
+	assert.Nil(t, err)
+
+	// Wrong password
+	_, err = NewEnvironmentP12(TestBaseURL, "./CA/test.crt", "./rp/test.p12", "wrong")
+	assert.NotNil(t, err)
+
+	// Missing files
+	_, err = NewEnvironmentP12(TestBaseURL, "INVALID_CA", "./rp/test.p12", "qwerty123")
+	assert.NotNil(t, err)
+	_, err = NewEnvironmentP12(TestBaseURL, "./CA/test.crt", "INVALID_P12", "qwerty123")
+	assert.NotNil(t, err)
+
+	// Password provider
+	env, err := NewEnvironmentP12Func(TestBaseURL, "./CA/test.crt", "./rp/test.p12", func() (string, error) {
+		return "qwerty123", nil
+	})
+	assert.Nil(t, err)
+	assert.NotNil(t, env.(TLSConfigurer).TLSConfig().Certificates[0].Leaf)
+
+	_, err = NewEnvironmentP12Func(TestBaseURL, "./CA/test.crt", "./rp/test.p12", func() (string, error) {
+		return "", fmt.Errorf("secret manager unavailable")
+	})
+	assert.NotNil(t, err)
+}
+
+func TestP12ModernEncryption(t *testing.T) {
+	root, rootKey := testCertificate(t, "Root", true, nil, nil)
+	intermediate, intermediateKey := testCertificate(t, "Intermediate", true, root, rootKey)
+	leaf, leafKey := testCertificate(t, "RP", false, intermediate, intermediateKey)
+	unrelated, _ := testCertificate(t, "Unrelated", true, nil, nil)
+
+	// AES-256/PBKDF2, with the leaf in the middle of the bag
+	p12Data, err := pkcs12.Modern.Encode(leafKey, intermediate, []*x509.Certificate{root, leaf, unrelated}, "s3cret")
+	assert.Nil(t, err)
+
+	rpCert, err := loadP12(p12Data, "s3cret")
+	assert.Nil(t, err)
+	assert.Equal(t, leaf.Raw, rpCert.Leaf.Raw)
+	assert.Equal(t, [][]byte{leaf.Raw, intermediate.Raw}, rpCert.Certificate)
+
+	_, err = loadP12(p12Data, "qwerty123")
+	assert.NotNil(t, err)
+
+	// Key without a matching certificate
+	p12Data, err = pkcs12.Modern.Encode(leafKey, intermediate, []*x509.Certificate{root}, "s3cret")
+	assert.Nil(t, err)
+	_, err = loadP12(p12Data, "s3cret")
+	assert.NotNil(t, err)
+
+	// Through the file based constructor
+	dir := t.TempDir()
+	p12Data, err = pkcs12.Modern.Encode(leafKey, leaf, []*x509.Certificate{intermediate, root}, "s3cret")
+	assert.Nil(t, err)
+	p12Path := filepath.Join(dir, "rp.p12")
+	assert.Nil(t, ioutil.WriteFile(p12Path, p12Data, 0600))
+
+	env, err := NewEnvironmentP12(TestBaseURL, "./CA/test.crt", p12Path, "s3cret")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(env.(TLSConfigurer).TLSConfig().Certificates[0].Certificate))
+}
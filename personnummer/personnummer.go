@@ -0,0 +1,171 @@
+// Package personnummer - parsing and validation of Swedish personal identity numbers
+// (personnummer) and coordination numbers (samordningsnummer).
+//
+// Accepts the 10 digit forms YYMMDD-NNNN, YYMMDD+NNNN (100 years or older) and YYMMDDNNNN,
+// and the 12 digit forms YYYYMMDDNNNN and YYYYMMDD-NNNN. String() gives the YYYYMMDDNNNN
+// form used by BankID.
+package personnummer
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalid - matches every error from this package with errors.Is()
+var ErrInvalid = errors.New("invalid personal number")
+
+var (
+	ErrFormat   = fmt.Errorf("%w: expected YYMMDD-NNNN or YYYYMMDDNNNN", ErrInvalid)
+	ErrDate     = fmt.Errorf("%w: no such date", ErrInvalid)
+	ErrChecksum = fmt.Errorf("%w: wrong check digit", ErrInvalid)
+)
+
+// Gender - legal gender as registered in the number
+type Gender string
+
+const (
+	Female Gender = "female"
+	Male   Gender = "male"
+)
+
+// coordinationOffset - added to the day of birth in a samordningsnummer
+const coordinationOffset = 60
+
+var format = regexp.MustCompile(`^(\d{2})?(\d{2})(\d{2})(\d{2})([-+]?)(\d{3})(\d)$`)
+
+// Number - a valid personnummer or samordningsnummer
+type Number struct {
+	Year         int  // Four digits
+	Month        int  // 1-12
+	Day          int  // Day of birth, without the samordningsnummer offset
+	Serial       int  // Birth number, 0-999
+	Check        int  // Luhn check digit
+	Coordination bool // A samordningsnummer, the day is written with 60 added
+}
+
+// Parse - same as ParseAt() with the current time
+func Parse(s string) (Number, error) {
+	return ParseAt(s, time.Now())
+}
+
+// ParseAt - parses s, the century of 10 digit forms is the latest one that puts
+// the date of birth before now, minus 100 years with a + separator
+func ParseAt(s string, now time.Time) (Number, error) {
+	m := format.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return Number{}, ErrFormat
+	}
+
+	yy, _ := strconv.Atoi(m[2])
+	month, _ := strconv.Atoi(m[3])
+	day, _ := strconv.Atoi(m[4])
+	serial, _ := strconv.Atoi(m[6])
+	check, _ := strconv.Atoi(m[7])
+
+	if luhn(m[2]+m[3]+m[4]+m[6]) != check {
+		return Number{}, ErrChecksum
+	}
+
+	n := Number{
+		Month:  month,
+		Day:    day,
+		Serial: serial,
+		Check:  check,
+	}
+	if day > coordinationOffset {
+		n.Day -= coordinationOffset
+		n.Coordination = true
+	}
+
+	if m[1] != "" {
+		century, _ := strconv.Atoi(m[1])
+		n.Year = century*100 + yy
+	} else {
+		n.Year = now.Year() - (now.Year()-yy)%100
+		if n.BirthDate().After(now) {
+			n.Year -= 100
+		}
+		if m[5] == "+" {
+			n.Year -= 100
+		}
+	}
+
+	if !validDate(n.Year, n.Month, n.Day) {
+		return Number{}, ErrDate
+	}
+	return n, nil
+}
+
+// Valid - true if s is a valid personnummer or samordningsnummer
+func Valid(s string) bool {
+	_, err := Parse(s)
+	return err == nil
+}
+
+// Normalize - s in the YYYYMMDDNNNN form
+func Normalize(s string) (string, error) {
+	n, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	return n.String(), nil
+}
+
+// String - YYYYMMDDNNNN, as BankID wants it
+func (n Number) String() string {
+	day := n.Day
+	if n.Coordination {
+		day += coordinationOffset
+	}
+	return fmt.Sprintf("%04d%02d%02d%03d%d", n.Year, n.Month, day, n.Serial, n.Check)
+}
+
+// BirthDate - midnight UTC on the day of birth
+func (n Number) BirthDate() time.Time {
+	return time.Date(n.Year, time.Month(n.Month), n.Day, 0, 0, 0, 0, time.UTC)
+}
+
+// AgeAt - age in whole years on the date of at, in its location.
+// Someone born on February 29 has their birthday on March 1 in other years
+func (n Number) AgeAt(at time.Time) int {
+	age := at.Year() - n.Year
+	if int(at.Month()) < n.Month || int(at.Month()) == n.Month && at.Day() < n.Day {
+		age--
+	}
+	return age
+}
+
+// Gender - legal gender, from the second to last digit
+func (n Number) Gender() Gender {
+	if n.Serial%2 == 1 {
+		return Male
+	}
+	return Female
+}
+
+func validDate(year int, month int, day int) bool {
+	if month < 1 || month > 12 || day < 1 {
+		return false
+	}
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC).Day() == day
+}
+
+// luhn - the check digit for the 9 digits YYMMDDNNN
+func luhn(digits string) int {
+	sum := 0
+	for i, r := range digits {
+		d := int(r - '0')
+		if i%2 == 0 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return (10 - sum%10) % 10
+}
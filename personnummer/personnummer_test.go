@@ -0,0 +1,120 @@
+package personnummer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var now = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+func TestParse(t *testing.T) {
+	for input, expected := range map[string]string{
+		"198001010001":  "198001010001",
+		"19800101-0001": "198001010001",
+		"800101-0001":   "198001010001",
+		"8001010001":    "198001010001",
+		" 800101-0001 ": "198001010001",
+		"800101+0001":   "188001010001",
+		"200107091241":  "200107091241",
+		"010709-1241":   "200107091241",
+		"701063-2391":   "197010632391", // Samordningsnummer
+		"197010632391":  "197010632391",
+		"121212-1212":   "201212121212",
+		"121212+1212":   "191212121212",
+		"261018-0008":   "202610180008", // Born today
+		"261019-0007":   "192610190007", // Not born yet, must be a hundred years ago
+		"20000229-0005": "200002290005",
+		"198001010100":  "198001010100",
+	} {
+		n, err := ParseAt(input, now)
+		if assert.NoError(t, err, input) {
+			assert.Equal(t, expected, n.String(), input)
+		}
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	n, err := ParseAt("701063-2391", now)
+	assert.NoError(t, err)
+	assert.Equal(t, Number{Year: 1970, Month: 10, Day: 3, Serial: 239, Check: 1, Coordination: true}, n)
+	assert.Equal(t, time.Date(1970, 10, 3, 0, 0, 0, 0, time.UTC), n.BirthDate())
+
+	n, err = ParseAt("19800101-0001", now)
+	assert.NoError(t, err)
+	assert.False(t, n.Coordination)
+	assert.Equal(t, 0, n.Serial)
+}
+
+func TestParseInvalid(t *testing.T) {
+	for input, expected := range map[string]error{
+		"":              ErrFormat,
+		"19800101000":   ErrFormat,
+		"1980010100011": ErrFormat,
+		"800101--0001":  ErrFormat,
+		"800101 0001":   ErrFormat,
+		"80O101-0001":   ErrFormat,
+		"198001010000":  ErrChecksum,
+		"800101-0002":   ErrChecksum,
+		"198013010007":  ErrDate,
+		"19800230-0005": ErrDate,
+		"19010229-0004": ErrDate,
+		"198001000002":  ErrDate,
+		"198001920001":  ErrDate, // Day 32 as a samordningsnummer
+	} {
+		_, err := ParseAt(input, now)
+		assert.Equal(t, expected, err, input)
+		assert.True(t, errors.Is(err, ErrInvalid), input)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	s, err := Normalize("800101-0001")
+	assert.NoError(t, err)
+	assert.Equal(t, "198001010001", s)
+
+	_, err = Normalize("800101-0000")
+	assert.True(t, errors.Is(err, ErrChecksum))
+
+	assert.True(t, Valid("19800101-0001"))
+	assert.False(t, Valid("19800101-0000"))
+}
+
+func TestLuhn(t *testing.T) {
+	assert.Equal(t, 1, luhn("800101000"))
+	assert.Equal(t, 2, luhn("121212121"))
+	assert.Equal(t, 0, luhn("000000000"))
+}
+
+func TestAgeAt(t *testing.T) {
+	n, _ := ParseAt("20000229-0005", now)
+	for at, expected := range map[string]int{
+		"2018-02-28": 17,
+		"2018-03-01": 18,
+		"2020-02-28": 19,
+		"2020-02-29": 20,
+		"2000-02-29": 0,
+	} {
+		date, _ := time.Parse("2006-01-02", at)
+		assert.Equal(t, expected, n.AgeAt(date), at)
+	}
+
+	n, _ = ParseAt("800101-0001", now)
+	assert.Equal(t, 46, n.AgeAt(now))
+	assert.Equal(t, 45, n.AgeAt(time.Date(2025, 12, 31, 23, 59, 0, 0, time.UTC)))
+}
+
+func TestGender(t *testing.T) {
+	for input, expected := range map[string]Gender{
+		"800101-0001":  Female,
+		"121212-1212":  Male,
+		"701063-2391":  Male,
+		"200107091241": Female,
+	} {
+		n, err := ParseAt(input, now)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, n.Gender(), input)
+	}
+}
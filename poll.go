@@ -0,0 +1,141 @@
+package bankid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PollInterval - BankID recommends calling collect every other second
+const PollInterval = 2 * time.Second
+
+// How long a cancel may take after the polling context is aborted
+var cancelTimeout = 5 * time.Second
+
+// ErrOrderFailed - matches every OrderFailedError, use with errors.Is()
+var ErrOrderFailed = errors.New("order failed")
+
+// OrderFailedError - the order ended with status OrderFailed
+type OrderFailedError struct {
+	OrderRef string
+	HintCode string // e.g FailUserCancel or FailExpiredTransaction
+}
+
+// Error -
+func (e *OrderFailedError) Error() string {
+	return fmt.Sprintf("order %s failed: %s", e.OrderRef, e.HintCode)
+}
+
+// Is - makes errors.Is(err, ErrOrderFailed) work
+func (e *OrderFailedError) Is(target error) bool {
+	return target == ErrOrderFailed
+}
+
+// PollConfig - optional settings for Poll and WaitForCompletion
+type PollConfig struct {
+	Interval time.Duration              // Time between collect calls, default PollInterval
+	OnChange func(rsp *CollectResponse) // Called for the first response and every change of status or hint code
+}
+
+type collectFunc func(ctx context.Context, orderRef string) (*CollectResponse, error)
+type cancelFunc func(ctx context.Context, orderRef string) error
+
+// Poll - calls collect until the order is complete or failed and returns the last response,
+// also on errors if there was one.
+// When ctx is aborted the order is cancelled and an *AbortedError returned, cfg may be nil.
+func Poll(ctx context.Context, env Environmenter, orderRef string, cfg *PollConfig) (*CollectResponse, error) {
+	return poll(ctx, orderRef, cfg,
+		func(ctx context.Context, orderRef string) (*CollectResponse, error) {
+			return CollectContext(ctx, env, orderRef)
+		},
+		func(ctx context.Context, orderRef string) error {
+			return CancelContext(ctx, env, orderRef)
+		})
+}
+
+// WaitForCompletion - same as Poll() but returns the completion data,
+// failed orders are returned as an *OrderFailedError
+func WaitForCompletion(ctx context.Context, env Environmenter, orderRef string, cfg *PollConfig) (*Completion, error) {
+	return completion(Poll(ctx, env, orderRef, cfg))
+}
+
+// Poll - see the package level Poll()
+func (c *Client) Poll(ctx context.Context, orderRef string, cfg *PollConfig) (*CollectResponse, error) {
+	return poll(ctx, orderRef, cfg, c.Collect, c.Cancel)
+}
+
+// WaitForCompletion - see the package level WaitForCompletion()
+func (c *Client) WaitForCompletion(ctx context.Context, orderRef string, cfg *PollConfig) (*Completion, error) {
+	return completion(c.Poll(ctx, orderRef, cfg))
+}
+
+func poll(ctx context.Context, orderRef string, cfg *PollConfig, collectFn collectFunc, cancelFn cancelFunc) (*CollectResponse, error) {
+	if cfg == nil {
+		cfg = &PollConfig{}
+	}
+
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = PollInterval
+	}
+
+	var last *CollectResponse
+	for {
+		rsp, err := collectFn(ctx, orderRef)
+		if err != nil {
+			if ctx.Err() != nil {
+				cancelOrder(orderRef, cancelFn)
+			}
+			return last, err
+		}
+
+		if cfg.OnChange != nil && (last == nil || last.Status != rsp.Status || last.HintCode != rsp.HintCode) {
+			cfg.OnChange(rsp)
+		}
+		last = rsp
+
+		if rsp.Status == OrderComplete || rsp.Status == OrderFailed {
+			return rsp, nil
+		}
+
+		// Don't spam the service plz
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			cancelOrder(orderRef, cancelFn)
+			return last, &AbortedError{
+				Endpoint: CollectEndpoint,
+				Timeout:  ctx.Err() == context.DeadlineExceeded,
+				Err:      ctx.Err(),
+			}
+		case <-timer.C:
+		}
+	}
+}
+
+// cancelOrder - best effort, the polling context is already done so use a fresh one
+func cancelOrder(orderRef string, cancelFn cancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), cancelTimeout)
+	defer cancel()
+	cancelFn(ctx, orderRef)
+}
+
+func completion(rsp *CollectResponse, err error) (*Completion, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	if rsp.Status == OrderFailed {
+		return nil, &OrderFailedError{
+			OrderRef: rsp.OrderRef,
+			HintCode: rsp.HintCode,
+		}
+	}
+
+	if rsp.CompletionData == nil {
+		return nil, fmt.Errorf("order %s is complete without completion data", rsp.OrderRef)
+	}
+	return rsp.CompletionData, nil
+}
@@ -0,0 +1,145 @@
+package bankid
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// scriptedEnv - answers collect with the scripted responses, repeating the last one
+type scriptedEnv struct {
+	testEnv
+	mu        sync.Mutex
+	responses []*CollectResponse
+	collects  int
+	cancels   int
+}
+
+func newScriptedEnv(responses ...*CollectResponse) *scriptedEnv {
+	env := &scriptedEnv{responses: responses}
+	env.handler = func(w http.ResponseWriter, r *http.Request) {
+		env.mu.Lock()
+		defer env.mu.Unlock()
+
+		if strings.HasSuffix(r.URL.Path, CancelEndpoint) {
+			env.cancels++
+			w.Write([]byte("{}"))
+			return
+		}
+
+		rsp := env.responses[len(env.responses)-1]
+		if env.collects < len(env.responses) {
+			rsp = env.responses[env.collects]
+		}
+		env.collects++
+		json.NewEncoder(w).Encode(rsp)
+	}
+	return env
+}
+
+func pending(hintCode string) *CollectResponse {
+	return &CollectResponse{OrderRef: "131daac9-16c6-4618-beb0-365768f37288", Status: OrderPending, HintCode: hintCode}
+}
+
+func TestPollComplete(t *testing.T) {
+	env := newScriptedEnv(
+		pending(PendOutstandingTransaction),
+		pending(PendOutstandingTransaction),
+		pending(PendStarted),
+		pending(PendUserSign),
+		&CollectResponse{
+			OrderRef:       "131daac9-16c6-4618-beb0-365768f37288",
+			Status:         OrderComplete,
+			CompletionData: &Completion{User: User{Name: "Karl Karlsson"}},
+		},
+	)
+
+	changes := []string{}
+	cfg := &PollConfig{
+		Interval: time.Millisecond,
+		OnChange: func(rsp *CollectResponse) {
+			changes = append(changes, rsp.Status+"/"+rsp.HintCode)
+		},
+	}
+
+	c, err := WaitForCompletion(context.Background(), env, "131daac9-16c6-4618-beb0-365768f37288", cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, "Karl Karlsson", c.User.Name)
+	assert.Equal(t, 5, env.collects)
+	assert.Equal(t, 0, env.cancels)
+	assert.Equal(t, []string{
+		"pending/outstandingTransaction",
+		"pending/started",
+		"pending/userSign",
+		"complete/",
+	}, changes)
+	env.server.Close()
+}
+
+func TestPollFailed(t *testing.T) {
+	env := newScriptedEnv(
+		pending(PendUserSign),
+		&CollectResponse{OrderRef: "131daac9-16c6-4618-beb0-365768f37288", Status: OrderFailed, HintCode: FailUserCancel},
+	)
+
+	rsp, err := Poll(context.Background(), env, "131daac9-16c6-4618-beb0-365768f37288", &PollConfig{Interval: time.Millisecond})
+	assert.Nil(t, err)
+	assert.Equal(t, OrderFailed, rsp.Status)
+
+	env.collects = 0
+	_, err = WaitForCompletion(context.Background(), env, "131daac9-16c6-4618-beb0-365768f37288", &PollConfig{Interval: time.Millisecond})
+	assert.True(t, errors.Is(err, ErrOrderFailed))
+
+	var failed *OrderFailedError
+	assert.True(t, errors.As(err, &failed))
+	assert.Equal(t, FailUserCancel, failed.HintCode)
+	assert.Equal(t, "131daac9-16c6-4618-beb0-365768f37288", failed.OrderRef)
+	env.server.Close()
+}
+
+func TestPollCollectError(t *testing.T) {
+	env := &testEnv{}
+	env.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"errorCode": "notFound", "details": "No such order"}`))
+	}
+
+	_, err := WaitForCompletion(context.Background(), env, "131daac9-16c6-4618-beb0-365768f37288", nil)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	env.server.Close()
+}
+
+func TestPollAbortedCancelsOrder(t *testing.T) {
+	env := newScriptedEnv(pending(PendOutstandingTransaction))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	rsp, err := Poll(ctx, env, "131daac9-16c6-4618-beb0-365768f37288", &PollConfig{Interval: 5 * time.Millisecond})
+	assert.True(t, errors.Is(err, ErrAborted))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	if assert.NotNil(t, rsp) {
+		assert.Equal(t, OrderPending, rsp.Status)
+	}
+	assert.Equal(t, 1, env.cancels)
+	env.server.Close()
+}
+
+func TestClientPoll(t *testing.T) {
+	env := newScriptedEnv(
+		pending(PendUserSign),
+		&CollectResponse{OrderRef: "131daac9-16c6-4618-beb0-365768f37288", Status: OrderFailed, HintCode: FailExpiredTransaction},
+	)
+	client := NewClient(env, nil)
+
+	_, err := client.WaitForCompletion(context.Background(), "131daac9-16c6-4618-beb0-365768f37288", &PollConfig{Interval: time.Millisecond})
+	assert.True(t, errors.Is(err, ErrOrderFailed))
+	env.server.Close()
+}
@@ -0,0 +1,122 @@
+package bankid
+
+// Animated QR codes for the "BankID on another device" flow
+//
+// The QR code shown to the user must be refreshed every second with data
+// derived from the qrStartToken and qrStartSecret of the Auth/Sign response:
+//
+//	bankid.<qrStartToken>.<seconds since order start>.<qrAuthCode>
+//
+// where qrAuthCode is HMAC-SHA256(qrStartSecret, seconds) as lowercase hex.
+// Keep the qrStartSecret on the server, only the final data should reach the browser.
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// Clock - returns the current time, replace it in tests
+type Clock func() time.Time
+
+// QR - keeps track of the animated QR code for one order
+type QR struct {
+	startToken  string
+	startSecret string
+	startTime   time.Time
+	now         Clock
+}
+
+// NewQR - starts the QR animation for an Auth/Sign response.
+// Call it right after the response arrives, the elapsed time is counted from here.
+// A nil clock uses time.Now
+func NewQR(rsp *Response, clock Clock) (*QR, error) {
+	if rsp == nil || rsp.QRStartToken == "" || rsp.QRStartSecret == "" {
+		return nil, fmt.Errorf("response is missing qrStartToken or qrStartSecret")
+	}
+
+	if clock == nil {
+		clock = time.Now
+	}
+
+	return &QR{
+		startToken:  rsp.QRStartToken,
+		startSecret: rsp.QRStartSecret,
+		startTime:   clock(),
+		now:         clock,
+	}, nil
+}
+
+// QRAuthCode - HMAC-SHA256 of the elapsed seconds keyed with the qrStartSecret, hex encoded
+func QRAuthCode(qrStartSecret string, seconds int) string {
+	mac := hmac.New(sha256.New, []byte(qrStartSecret))
+	mac.Write([]byte(strconv.Itoa(seconds)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// QRData - the QR code payload for an order after the provided number of seconds
+func QRData(qrStartToken string, qrStartSecret string, seconds int) string {
+	return fmt.Sprintf("bankid.%s.%d.%s", qrStartToken, seconds, QRAuthCode(qrStartSecret, seconds))
+}
+
+// Elapsed - whole seconds since the order started
+func (q *QR) Elapsed() int {
+	elapsed := q.now().Sub(q.startTime)
+	if elapsed < 0 {
+		return 0
+	}
+	return int(elapsed / time.Second)
+}
+
+// Data - the QR code payload to show right now
+func (q *QR) Data() string {
+	return q.DataAt(q.Elapsed())
+}
+
+// DataAt - the QR code payload for any elapsed second
+func (q *QR) DataAt(seconds int) string {
+	return QRData(q.startToken, q.startSecret, seconds)
+}
+
+// PNG - the current QR code as a size x size pixels PNG image
+func (q *QR) PNG(size int) ([]byte, error) {
+	code, err := qrcode.New(q.Data(), qrcode.Low)
+	if err != nil {
+		return nil, fmt.Errorf("could not create QR code: %s", err.Error())
+	}
+	return code.PNG(size)
+}
+
+// SVG - the current QR code as a size x size SVG image
+func (q *QR) SVG(size int) ([]byte, error) {
+	code, err := qrcode.New(q.Data(), qrcode.Low)
+	if err != nil {
+		return nil, fmt.Errorf("could not create QR code: %s", err.Error())
+	}
+	return renderSVG(code.Bitmap(), size), nil
+}
+
+// renderSVG - one path with a square per dark module, scaled by the viewBox
+func renderSVG(bitmap [][]bool, size int) []byte {
+	modules := len(bitmap)
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, modules, modules)
+	fmt.Fprintf(buf, `<rect width="%d" height="%d" fill="#fff"/>`, modules, modules)
+	buf.WriteString(`<path fill="#000" d="`)
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(buf, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes()
+}
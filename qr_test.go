@@ -0,0 +1,77 @@
+package bankid
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Example values from the BankID QR code documentation
+var qrResponse = &Response{
+	QRStartToken:  "67df3917-fa0d-44e5-b327-edcc928297f8",
+	QRStartSecret: "d28db9a7-4cde-429e-a983-359be676944c",
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func TestQRAuthCode(t *testing.T) {
+	assert.Equal(t, "dc69358e712458a66a7525beef148ae8526b1c71610eff2c16cdffb4cdac9bf8", QRAuthCode(qrResponse.QRStartSecret, 0))
+	assert.Equal(t, "949d559bf23403952a94d103e67743126381eda00f0b3cbddbf7c96b1adcbce2", QRAuthCode(qrResponse.QRStartSecret, 1))
+	assert.Equal(t, "a9e5ec59cb4eee4ef4117150abc58fad7a85439a6a96ccbecc3668b41795b3f3", QRAuthCode(qrResponse.QRStartSecret, 2))
+}
+
+func TestQRMissingTokens(t *testing.T) {
+	_, err := NewQR(nil, nil)
+	assert.NotNil(t, err)
+
+	_, err = NewQR(&Response{QRStartToken: "token"}, nil)
+	assert.NotNil(t, err)
+}
+
+func TestQRAnimation(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}
+	qr, err := NewQR(qrResponse, clock.Now)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 0, qr.Elapsed())
+	assert.Equal(t, "bankid.67df3917-fa0d-44e5-b327-edcc928297f8.0.dc69358e712458a66a7525beef148ae8526b1c71610eff2c16cdffb4cdac9bf8", qr.Data())
+
+	clock.now = clock.now.Add(1500 * time.Millisecond)
+	assert.Equal(t, 1, qr.Elapsed())
+	assert.Equal(t, "bankid.67df3917-fa0d-44e5-b327-edcc928297f8.1.949d559bf23403952a94d103e67743126381eda00f0b3cbddbf7c96b1adcbce2", qr.Data())
+
+	clock.now = clock.now.Add(time.Second)
+	assert.Equal(t, qr.DataAt(2), qr.Data())
+
+	// Clock going backwards
+	clock.now = clock.now.Add(-time.Hour)
+	assert.Equal(t, 0, qr.Elapsed())
+}
+
+func TestQRImages(t *testing.T) {
+	qr, err := NewQR(qrResponse, nil)
+	assert.Nil(t, err)
+
+	pngData, err := qr.PNG(256)
+	assert.Nil(t, err)
+	img, err := png.Decode(bytes.NewReader(pngData))
+	assert.Nil(t, err)
+	assert.Equal(t, 256, img.Bounds().Dx())
+
+	svgData, err := qr.SVG(256)
+	assert.Nil(t, err)
+	svg := string(svgData)
+	assert.True(t, strings.HasPrefix(svg, "<svg "))
+	assert.Contains(t, svg, `width="256"`)
+	assert.Contains(t, svg, "h1v1h-1z")
+}
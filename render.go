@@ -0,0 +1,86 @@
+package bankid
+
+// Rendering messages for display, with placeholders
+
+import (
+	"html"
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+// Params - values for the {name} placeholders of custom messages, e.g {"service": "Example Bank"}
+type Params map[string]string
+
+var (
+	placeholder = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+	link        = regexp.MustCompile(`https?://[^\s<>"]+`)
+	markdown    = strings.NewReplacer(
+		`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`,
+		`<`, `\<`, `>`, `\>`, `#`, `\#`, `|`, `\|`, `~`, `\~`,
+	)
+)
+
+// WithParams - the messages with default placeholder values, params given when rendering take precedence
+func (m *Messages) WithParams(params Params) *Messages {
+	merged := Params{}
+	for name, value := range m.params {
+		merged[name] = value
+	}
+	for name, value := range params {
+		merged[name] = value
+	}
+	return &Messages{lang: m.lang, chain: m.chain, params: merged}
+}
+
+// Text - the message with its placeholders filled in. Placeholders without a value are left as they are
+func (m *Messages) Text(key string, params Params) string {
+	return placeholder.ReplaceAllStringFunc(m.Msg(key), func(match string) string {
+		name := match[1 : len(match)-1]
+		if value, ok := params[name]; ok {
+			return value
+		}
+		if value, ok := m.params[name]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+// HTML - Text() escaped for HTML, with links for the URLs and <br> for the line breaks
+func (m *Messages) HTML(key string, params Params) template.HTML {
+	var b strings.Builder
+	renderLinks(m.Text(key, params), func(text string) {
+		b.WriteString(strings.ReplaceAll(html.EscapeString(text), "\n", "<br>\n"))
+	}, func(url string) {
+		escaped := html.EscapeString(url)
+		b.WriteString(`<a href="` + escaped + `">` + escaped + `</a>`)
+	})
+	return template.HTML(b.String())
+}
+
+// Markdown - Text() with the Markdown syntax escaped, autolinks for the URLs and hard line breaks
+func (m *Messages) Markdown(key string, params Params) string {
+	var b strings.Builder
+	renderLinks(m.Text(key, params), func(text string) {
+		b.WriteString(strings.ReplaceAll(markdown.Replace(text), "\n", "\\\n"))
+	}, func(url string) {
+		b.WriteString("<" + url + ">")
+	})
+	return b.String()
+}
+
+// renderLinks - splits s in text and URLs. Punctuation ending a sentence is not part of the URL
+func renderLinks(s string, text func(string), url func(string)) {
+	last := 0
+	for _, loc := range link.FindAllStringIndex(s, -1) {
+		end := loc[1]
+		for end > loc[0] && strings.ContainsRune(".,;:!?)'", rune(s[end-1])) {
+			end--
+		}
+		text(s[last:loc[0]])
+		url(s[loc[0]:end])
+		last = end
+	}
+	text(s[last:])
+}
@@ -0,0 +1,62 @@
+package bankid
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestRenderText(t *testing.T) {
+	m, _ := NewMessages("sv")
+	assert.Equal(t, m.Msg(RFA17_B), m.Text(RFA17_B, nil))
+	assert.Equal(t, "", m.Text("NOPE", nil))
+}
+
+func TestRenderHTML(t *testing.T) {
+	m, _ := NewMessages("sv")
+
+	rendered := m.HTML(RFA17_B, nil)
+	first := strings.SplitN(m.Msg(RFA17_B), "\n", 2)[0]
+	assert.True(t, strings.HasPrefix(string(rendered), first+"<br>\nOm du"))
+	// The full stop ends the sentence, not the URL
+	assert.True(t, strings.HasSuffix(string(rendered), `<a href="https://install.bankid.com">https://install.bankid.com</a>.`))
+
+	c := NewCatalog()
+	c.Add(language.English, map[string]string{RFA1: `Start the app <b>"{service}"</b> & see https://example.com/?a=1&b=<2>`})
+	assert.Equal(t,
+		template.HTML(`Start the app &lt;b&gt;&#34;&lt;script&gt;&#34;&lt;/b&gt; &amp; see <a href="https://example.com/?a=1&amp;b=">https://example.com/?a=1&amp;b=</a>&lt;2&gt;`),
+		c.Messages().HTML(RFA1, Params{"service": "<script>"}))
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	m, _ := NewMessages("en")
+	rendered := m.Markdown(RFA17_A, nil)
+	assert.True(t, strings.HasSuffix(rendered, "from your app store or <https://install.bankid.com>."))
+
+	c := NewCatalog()
+	c.Add(language.English, map[string]string{RFA1: "Log in to {service}\n*now* at http://example.com/login_page"})
+	assert.Equal(t, "Log in to \\[my\\_bank\\]\\\n\\*now\\* at <http://example.com/login_page>",
+		c.Messages().Markdown(RFA1, Params{"service": "[my_bank]"}))
+}
+
+func TestRenderParams(t *testing.T) {
+	c := NewCatalog()
+	c.Add(language.English, map[string]string{RFA1: "Start the BankID app to log in to {service} as {user}. {unknown} {not a placeholder}"})
+	m := c.Messages().WithParams(Params{"service": "Example Bank", "user": "someone"})
+
+	assert.Equal(t, "Start the BankID app to log in to Example Bank as Anna. {unknown} {not a placeholder}",
+		m.Text(RFA1, Params{"user": "Anna"}))
+	assert.Equal(t, "Start the BankID app to log in to Example Bank as someone. {unknown} {not a placeholder}",
+		m.Text(RFA1, nil))
+	assert.Equal(t, m.Language(), c.Messages().Language())
+
+	// The defaults are copied, not shared
+	other := m.WithParams(Params{"service": "Other"})
+	assert.Contains(t, m.Text(RFA1, nil), "Example Bank")
+	assert.Contains(t, other.Text(RFA1, nil), "Other")
+	// Values are not expanded again
+	assert.Contains(t, m.Text(RFA1, Params{"user": "{service}"}), "as {service}.")
+}
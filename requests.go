@@ -1,9 +1,12 @@
 package bankid
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 )
 
@@ -32,40 +35,83 @@ type responseParser func(*http.Response) (interface{}, error)
 //
 // The Sign() method will base64-encode both the UserVisible and UserNonVisible data.
 // Choose whichever line ending character you need.
+// The limits are checked before calling BankID, oversized data fails with a *FieldError.
 func Sign(env Environmenter, personalNumber string, userIP string, userVisible string, userNonVisible string) (*Response, error) {
+	return SignContext(context.Background(), env, personalNumber, userIP, userVisible, userNonVisible)
+}
 
-	// Base64 encode with padding
-	if userVisible != "" {
-		userVisible = base64.StdEncoding.EncodeToString([]byte(userVisible))
-	}
-
-	if userNonVisible != "" {
-		userNonVisible = base64.StdEncoding.EncodeToString([]byte(userNonVisible))
-	}
-
-	requestBody := Request{
+// SignContext - same as Sign() but aborted when ctx is done
+func SignContext(ctx context.Context, env Environmenter, personalNumber string, userIP string, userVisible string, userNonVisible string) (*Response, error) {
+	return SignRequestContext(ctx, env, &Request{
 		PersonalNumber:     personalNumber,
 		EndUserIP:          userIP,
 		UserVisibleData:    userVisible,
 		UserNonVisibleData: userNonVisible,
+	})
+}
+
+// SignRequest - same as Sign() but with access to every request field,
+// e.g ReturnURL and UserVisibleDataFormat in v6.
+// UserVisibleData and UserNonVisibleData are base64-encoded for you.
+func SignRequest(env Environmenter, request *Request) (*Response, error) {
+	return SignRequestContext(context.Background(), env, request)
+}
+
+// SignRequestContext - same as SignRequest() but aborted when ctx is done
+func SignRequestContext(ctx context.Context, env Environmenter, request *Request) (*Response, error) {
+	return startOrder(ctx, nil, env, SignEndpoint, request)
+}
+
+// encodeUserData - base64 encode the user visible and non-visible data
+func encodeUserData(request Request) Request {
+	// Base64 encode with padding
+	if request.UserVisibleData != "" {
+		request.UserVisibleData = base64.StdEncoding.EncodeToString([]byte(request.UserVisibleData))
 	}
 
-	output := &Response{}
-	rsp, err := call(SignEndpoint, env, &requestBody, stdResponseParser)
-	if err == nil && rsp != nil {
-		output = rsp.(*Response)
+	if request.UserNonVisibleData != "" {
+		request.UserNonVisibleData = base64.StdEncoding.EncodeToString([]byte(request.UserNonVisibleData))
 	}
-	return output, err
+	return request
 }
 
 // Auth - verify a users identity
 func Auth(env Environmenter, personalNumber string, userIP string) (*Response, error) {
-	requestBody := Request{
+	return AuthContext(context.Background(), env, personalNumber, userIP)
+}
+
+// AuthContext - same as Auth() but aborted when ctx is done
+func AuthContext(ctx context.Context, env Environmenter, personalNumber string, userIP string) (*Response, error) {
+	return AuthRequestContext(ctx, env, &Request{
 		PersonalNumber: personalNumber,
 		EndUserIP:      userIP,
+	})
+}
+
+// AuthRequest - same as Auth() but with access to every request field.
+// UserVisibleData and UserNonVisibleData are base64-encoded for you.
+func AuthRequest(env Environmenter, request *Request) (*Response, error) {
+	return AuthRequestContext(context.Background(), env, request)
+}
+
+// AuthRequestContext - same as AuthRequest() but aborted when ctx is done
+func AuthRequestContext(ctx context.Context, env Environmenter, request *Request) (*Response, error) {
+	return startOrder(ctx, nil, env, AuthEndpoint, request)
+}
+
+// startOrder - Auth or Sign, a nil client means a new one from env
+func startOrder(ctx context.Context, client *http.Client, env Environmenter, endpoint string, request *Request) (*Response, error) {
+	requestBody, err := normalizePersonalNumber(*request)
+	if err == nil {
+		err = validateRequest(endpoint, &requestBody)
+	}
+	if err != nil {
+		return &Response{}, fmt.Errorf("could not start order: %w", err)
 	}
+	requestBody = encodeUserData(requestBody)
+
 	output := &Response{}
-	rsp, err := call(AuthEndpoint, env, &requestBody, stdResponseParser)
+	rsp, err := callWith(ctx, client, endpoint, env, &requestBody, stdResponseParser)
 	if err == nil && rsp != nil {
 		output = rsp.(*Response)
 	}
@@ -73,12 +119,22 @@ func Auth(env Environmenter, personalNumber string, userIP string) (*Response, e
 }
 
 func Collect(env Environmenter, orderRef string) (*CollectResponse, error) {
+	return CollectContext(context.Background(), env, orderRef)
+}
+
+// CollectContext - same as Collect() but aborted when ctx is done
+func CollectContext(ctx context.Context, env Environmenter, orderRef string) (*CollectResponse, error) {
+	return collect(ctx, nil, env, orderRef)
+}
+
+// collect - a nil client means a new one from env
+func collect(ctx context.Context, client *http.Client, env Environmenter, orderRef string) (*CollectResponse, error) {
 	requestBody := Request{
 		OrderRef: orderRef,
 	}
 
 	output := &CollectResponse{}
-	rsp, err := call(CollectEndpoint, env, &requestBody, collectParser)
+	rsp, err := callWith(ctx, client, CollectEndpoint, env, &requestBody, collectParser)
 	if err == nil && rsp != nil {
 		output = rsp.(*CollectResponse)
 	}
@@ -87,25 +143,48 @@ func Collect(env Environmenter, orderRef string) (*CollectResponse, error) {
 
 // Cancel -
 func Cancel(env Environmenter, orderRef string) error {
+	return CancelContext(context.Background(), env, orderRef)
+}
+
+// CancelContext - same as Cancel() but aborted when ctx is done
+func CancelContext(ctx context.Context, env Environmenter, orderRef string) error {
+	return cancel(ctx, nil, env, orderRef)
+}
+
+// cancel - a nil client means a new one from env
+func cancel(ctx context.Context, client *http.Client, env Environmenter, orderRef string) error {
 	requestBody := Request{
 		OrderRef: orderRef,
 	}
-	_, err := call(CancelEndpoint, env, &requestBody, stdResponseParser)
+	_, err := callWith(ctx, client, CancelEndpoint, env, &requestBody, stdResponseParser)
 	return err
 }
 
-func call(endpoint string, env Environmenter, requestBody *Request, rspParser responseParser) (interface{}, error) {
+func call(ctx context.Context, endpoint string, env Environmenter, requestBody *Request, rspParser responseParser) (interface{}, error) {
+	return callWith(ctx, nil, endpoint, env, requestBody, rspParser)
+}
+
+// callWith - same as call() but on a shared client, nil bakes a new client from env
+func callWith(ctx context.Context, client *http.Client, endpoint string, env Environmenter, requestBody *Request, rspParser responseParser) (interface{}, error) {
 
-	req, err := env.NewRequest(endpoint, requestBody)
+	var body interface{} = requestBody
+	if requestBody != nil {
+		versioned := requestBody.forVersion(apiVersion(env))
+		body = &versioned
+	}
+
+	req, err := env.NewRequest(ctx, endpoint, body)
 	if err != nil {
 		return nil, err
 	}
 
-	client := env.NewClient() // A http.Client with a HTTP Mutal Authentication loaded
+	if client == nil {
+		client = env.NewClient() // A http.Client with a HTTP Mutal Authentication loaded
+	}
 
 	rsp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, abortedOr(ctx, endpoint, err)
 	}
 
 	return rspParser(rsp)
@@ -126,12 +205,7 @@ func stdResponseParser(rsp *http.Response) (interface{}, error) {
 
 	// Fail
 	if rsp.StatusCode >= 400 {
-		errRsp := ErrorResponse{}
-		err := json.NewDecoder(rsp.Body).Decode(&errRsp)
-		if err != nil {
-			return nil, fmt.Errorf(" !! failed to parse fail response: %s", err.Error())
-		}
-		return nil, errRsp // A bit unorthodox but ErrorResponse is a proper error type
+		return nil, parseErrorResponse(rsp)
 	}
 
 	// We don't care about HTTP 1xx messages
@@ -153,13 +227,31 @@ func collectParser(rsp *http.Response) (interface{}, error) {
 
 	// Fail
 	if rsp.StatusCode >= 400 {
-		errRsp := ErrorResponse{}
-		err := json.NewDecoder(rsp.Body).Decode(&errRsp)
-		if err != nil {
-			return nil, fmt.Errorf(" !! failed to parse fail response: %s", err.Error())
-		}
-		return nil, errRsp // A bit unorthodox but ErrorResponse is a proper error type
+		return nil, parseErrorResponse(rsp)
 	}
 	// We don't care about HTTP 1xx messages
 	return nil, nil
 }
+
+// parseErrorResponse - an ErrorResponse, or a *TransportError if the body isn't one
+func parseErrorResponse(rsp *http.Response) error {
+	body, err := ioutil.ReadAll(io.LimitReader(rsp.Body, 64*1024))
+	if err == nil {
+		errRsp := ErrorResponse{}
+		err = json.Unmarshal(body, &errRsp)
+		if err == nil {
+			errRsp.StatusCode = rsp.StatusCode
+			return errRsp // A bit unorthodox but ErrorResponse is a proper error type
+		}
+	}
+
+	if len(body) > 512 {
+		body = body[:512]
+	}
+	return &TransportError{
+		StatusCode:  rsp.StatusCode,
+		ContentType: rsp.Header.Get("Content-Type"),
+		Body:        string(body),
+		Err:         err,
+	}
+}
@@ -3,6 +3,7 @@ package bankid
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/onlyangel/bankid/personnummer"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -35,7 +37,7 @@ func (t *testEnv) NewClient() *http.Client {
 		Timeout: 10 * time.Second,
 	}
 }
-func (t *testEnv) NewRequest(endpoint string, body interface{}) (*http.Request, error) {
+func (t *testEnv) NewRequest(ctx context.Context, endpoint string, body interface{}) (*http.Request, error) {
 	t.server = httptest.NewServer(http.HandlerFunc(t.handler))
 
 	fmt.Println("Test server at ", t.server.Listener.Addr().String())
@@ -47,7 +49,7 @@ func (t *testEnv) NewRequest(endpoint string, body interface{}) (*http.Request,
 
 	// fmt.Println("Request to: ", APIVersion+endpoint)
 	bodyReader := strings.NewReader(string(requestBody))
-	req, err := http.NewRequest("POST", "http://"+t.server.URL+"/"+APIVersion+endpoint, bodyReader)
+	req, err := http.NewRequestWithContext(ctx, "POST", "http://"+t.server.URL+"/"+APIVersion+endpoint, bodyReader)
 	if err != nil {
 		return nil, err
 	}
@@ -135,7 +137,7 @@ func TestSignAuthCollect_v5(t *testing.T) {
 	for _, at := range testFunctions {
 		env.handler = at.handler
 		fmt.Printf("Sign: %s - ", at.name)
-		resp, err := Sign(env, "198001010000", "127.0.0.1", "Hi User", "abc123")
+		resp, err := Sign(env, "198001010001", "127.0.0.1", "Hi User", "abc123")
 		at.assert(resp, err)
 	}
 
@@ -143,7 +145,7 @@ func TestSignAuthCollect_v5(t *testing.T) {
 	for _, at := range testFunctions {
 		env.handler = at.handler
 		fmt.Printf("Auth: %s - ", at.name)
-		resp, err := Auth(env, "198001010000", "127.0.0.1")
+		resp, err := Auth(env, "198001010001", "127.0.0.1")
 		at.assert(resp, err)
 	}
 
@@ -215,7 +217,7 @@ type invalidEnv struct {
 	requestError error
 }
 
-func (t *invalidEnv) NewRequest(endpoint string, body interface{}) (*http.Request, error) {
+func (t *invalidEnv) NewRequest(_ context.Context, endpoint string, body interface{}) (*http.Request, error) {
 	return t.request, t.requestError
 }
 
@@ -224,12 +226,190 @@ func TestCallMethod(t *testing.T) {
 
 	env.request = &http.Request{}
 	env.requestError = nil
-	req, err := call("", env, nil, nil)
+	req, err := call(context.Background(), "", env, nil, nil)
 	assert.Nil(t, req)
 	assert.NotNil(t, err)
 
 	env.requestError = fmt.Errorf("fake invalid response")
-	req, err = call("", env, nil, nil)
+	req, err = call(context.Background(), "", env, nil, nil)
 	assert.Nil(t, req)
 	assert.NotNil(t, err)
 }
+
+//
+// API version differences
+//
+
+type versionedTestEnv struct {
+	testEnv
+	version string
+}
+
+func (t *versionedTestEnv) APIVersion() string {
+	return t.version
+}
+
+func TestRequestShape_v6(t *testing.T) {
+	received := map[string]interface{}{}
+	env := &versionedTestEnv{version: APIVersionV6}
+	env.handler = func(w http.ResponseWriter, r *http.Request) {
+		received = map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&received)
+		json.NewEncoder(w).Encode(&Response{
+			OrderRef:       "131daac9-16c6-4618-beb0-365768f37288",
+			AutoStartToken: "7c40b5c9-fa74-49cf-b98c-bfe651f9a7c6",
+			QRStartToken:   "67df3917-fa0d-44e5-b327-edcc928297f8",
+			QRStartSecret:  "d28db9a7-4cde-429e-a983-359be676944c",
+		})
+	}
+
+	rsp, err := AuthRequest(env, &Request{
+		PersonalNumber:        "198001010001",
+		EndUserIP:             "127.0.0.1",
+		UserVisibleData:       "Hi *User*",
+		UserVisibleDataFormat: FormatSimpleMarkdown,
+		ReturnURL:             "https://example.com/return",
+		Requirement:           &Requirement{PinCode: true},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "67df3917-fa0d-44e5-b327-edcc928297f8", rsp.QRStartToken)
+	assert.Equal(t, "d28db9a7-4cde-429e-a983-359be676944c", rsp.QRStartSecret)
+
+	// Personal number moved into the requirement
+	assert.NotContains(t, received, "personalNumber")
+	assert.Equal(t, map[string]interface{}{"personalNumber": "198001010001", "pinCode": true}, received["requirement"])
+	assert.Equal(t, "SGkgKlVzZXIq", received["userVisibleData"])
+	assert.Equal(t, FormatSimpleMarkdown, received["userVisibleDataFormat"])
+	assert.Equal(t, "https://example.com/return", received["returnUrl"])
+
+	// v5 keeps the personal number at the top
+	env.version = APIVersionV5
+	_, err = Sign(env, "198001010001", "127.0.0.1", "Hi User", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "198001010001", received["personalNumber"])
+	assert.NotContains(t, received, "requirement")
+
+	env.server.Close()
+}
+
+func TestPersonalNumberValidation(t *testing.T) {
+	calls := 0
+	received := map[string]interface{}{}
+	env := &versionedTestEnv{version: APIVersionV6}
+	env.handler = func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		received = map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&received)
+		json.NewEncoder(w).Encode(&Response{OrderRef: "131daac9-16c6-4618-beb0-365768f37288"})
+	}
+
+	// Never reaches the network
+	_, err := Auth(env, "198001010000", "127.0.0.1")
+	assert.True(t, errors.Is(err, personnummer.ErrChecksum))
+	_, err = Sign(env, "19801301-0007", "127.0.0.1", "Hi User", "")
+	assert.True(t, errors.Is(err, personnummer.ErrDate))
+	_, err = AuthRequest(env, &Request{EndUserIP: "127.0.0.1", Requirement: &Requirement{PersonalNumber: "8001010001x"}})
+	assert.True(t, errors.Is(err, personnummer.ErrInvalid))
+	assert.Equal(t, 0, calls)
+
+	// Normalized before it's sent, without touching the callers request
+	request := &Request{EndUserIP: "127.0.0.1", Requirement: &Requirement{PersonalNumber: "800101-0001"}}
+	_, err = AuthRequest(env, request)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"personalNumber": "198001010001"}, received["requirement"])
+	assert.Equal(t, "800101-0001", request.Requirement.PersonalNumber)
+
+	_, err = Auth(env, "19800101-0001", "127.0.0.1")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"personalNumber": "198001010001"}, received["requirement"])
+	assert.Equal(t, 2, calls)
+
+	env.server.Close()
+}
+
+func TestCollectCompletion_v6(t *testing.T) {
+	env := &versionedTestEnv{version: APIVersionV6}
+	env.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{
+			"orderRef": "131daac9-16c6-4618-beb0-365768f37288",
+			"status": "complete",
+			"completionData": {
+				"user": {"personalNumber": "190000000000", "name": "Karl Karlsson", "givenName": "Karl", "surname": "Karlsson"},
+				"device": {"ipAddress": "192.168.0.1", "uhi": "OZvYM9VvyiAmG7NA5jU5zRGcHTO1Bq"},
+				"bankIdIssueDate": "2020-02-01",
+				"stepUp": {"mrtd": true},
+				"signature": "c2lnbmF0dXJl",
+				"ocspResponse": "b2NzcA==",
+				"risk": "low"
+			}
+		}`))
+	}
+
+	rsp, err := Collect(env, "131daac9-16c6-4618-beb0-365768f37288")
+	assert.Nil(t, err)
+	assert.Equal(t, OrderComplete, rsp.Status)
+	assert.Equal(t, "Karl", rsp.CompletionData.User.GivenName)
+	assert.Equal(t, "OZvYM9VvyiAmG7NA5jU5zRGcHTO1Bq", rsp.CompletionData.Device.UHI)
+	assert.Equal(t, "2020-02-01", rsp.CompletionData.BankIDIssueDate)
+	assert.True(t, rsp.CompletionData.StepUp.Mrtd)
+	assert.Equal(t, "low", rsp.CompletionData.Risk)
+
+	env.server.Close()
+}
+
+//
+// Context handling
+//
+
+func TestContextCancelled(t *testing.T) {
+	env := &testEnv{}
+	env.handler = func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&Response{})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := AuthContext(ctx, env, "198001010001", "127.0.0.1")
+	assert.True(t, errors.Is(err, ErrAborted))
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	var aborted *AbortedError
+	assert.True(t, errors.As(err, &aborted))
+	assert.False(t, aborted.Timeout)
+	assert.Equal(t, AuthEndpoint, aborted.Endpoint)
+
+	_, err = SignContext(ctx, env, "198001010001", "127.0.0.1", "Hi User", "")
+	assert.True(t, errors.Is(err, ErrAborted))
+
+	_, err = CollectContext(ctx, env, "dbbee61c-357b-4fd8-b103-392eed10be7a")
+	assert.True(t, errors.Is(err, ErrAborted))
+
+	err = CancelContext(ctx, env, "dbbee61c-357b-4fd8-b103-392eed10be7a")
+	assert.True(t, errors.Is(err, ErrAborted))
+
+	env.server.Close()
+}
+
+func TestContextDeadline(t *testing.T) {
+	release := make(chan struct{})
+	env := &testEnv{}
+	env.handler = func(w http.ResponseWriter, r *http.Request) {
+		<-release // Slow BankID
+		json.NewEncoder(w).Encode(&CollectResponse{})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := CollectContext(ctx, env, "dbbee61c-357b-4fd8-b103-392eed10be7a")
+	assert.True(t, errors.Is(err, ErrAborted))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	var aborted *AbortedError
+	assert.True(t, errors.As(err, &aborted))
+	assert.True(t, aborted.Timeout)
+
+	close(release)
+	env.server.Close()
+}
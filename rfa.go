@@ -0,0 +1,102 @@
+package bankid
+
+// Hint codes and errors to the user messages of the RP guidelines
+
+import "errors"
+
+// MessageContext - how the order was started and where the user is, picks between the message variants.
+// The time since the order started is not part of it: the decision table of the RP guidelines has no
+// time based switch. When autostart fails BankID ends the order with startFailed, which gives RFA17_A
+type MessageContext struct {
+	AutoStart bool // The app was started with the autostart token on this device. False for QR codes
+	Mobile    bool // The user is on a phone or tablet, the B variants. See UserAgent.Mobile()
+}
+
+// RFA - the RFA message key for a collect response, following the RP guidelines.
+// Empty for complete orders. ctx may be nil for a QR code shown on a desktop
+func RFA(rsp *CollectResponse, ctx *MessageContext) string {
+	if ctx == nil {
+		ctx = &MessageContext{}
+	}
+	if rsp == nil {
+		return RFA22
+	}
+
+	switch rsp.Status {
+	case OrderPending:
+		switch rsp.HintCode {
+		case PendOutstandingTransaction:
+			// Until the app starts, or BankID gives up with startFailed
+			if ctx.AutoStart {
+				return RFA13
+			}
+			return RFA1
+		case PendNoClient:
+			return RFA1
+		case PendStarted:
+			// Only suggest another device when the order could be done there
+			if ctx.AutoStart {
+				return variant(ctx, RFA15_A, RFA15_B)
+			}
+			return variant(ctx, RFA14_A, RFA14_B)
+		case PendUserSign:
+			return RFA9
+		}
+		return RFA21
+	case OrderFailed:
+		switch rsp.HintCode {
+		case FailExpiredTransaction:
+			return RFA8
+		case FailCertificateErr:
+			return RFA16
+		case FailUserCancel:
+			return RFA6
+		case FailCancelled:
+			return RFA3
+		case FailStartFailed:
+			if ctx.AutoStart {
+				return RFA17_A
+			}
+			return RFA17_B
+		}
+		return RFA22
+	case OrderComplete:
+		return ""
+	}
+	return RFA22
+}
+
+// RFAForError - the RFA message key for an error from Auth, Sign, Collect or Cancel. Empty for nil
+func RFAForError(err error) string {
+	var transportErr *TransportError
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrAlreadyInProgress):
+		return RFA4
+	case errors.Is(err, ErrRequestTimeout), errors.Is(err, ErrMaintenance), errors.Is(err, ErrInternalError):
+		return RFA5
+	case errors.Is(err, ErrAborted), errors.As(err, &transportErr):
+		return RFA5
+	}
+	return RFA22
+}
+
+func variant(ctx *MessageContext, desktop string, mobile string) string {
+	if ctx.Mobile {
+		return mobile
+	}
+	return desktop
+}
+
+// ForCollect - RFA() with the message text, both empty for complete orders
+func (m *Messages) ForCollect(rsp *CollectResponse, ctx *MessageContext) (string, string) {
+	key := RFA(rsp, ctx)
+	return key, m.Msg(key)
+}
+
+// ForError - RFAForError() with the message text
+func (m *Messages) ForError(err error) (string, string) {
+	key := RFAForError(err)
+	return key, m.Msg(key)
+}
@@ -0,0 +1,77 @@
+package bankid
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRFA(t *testing.T) {
+	qr := &MessageContext{}
+	qrMobile := &MessageContext{Mobile: true}
+	autoStart := &MessageContext{AutoStart: true}
+	autoStartMobile := &MessageContext{AutoStart: true, Mobile: true}
+
+	for _, test := range []struct {
+		status   string
+		hintCode string
+		ctx      *MessageContext
+		expected string
+	}{
+		{OrderPending, PendOutstandingTransaction, qr, RFA1},
+		{OrderPending, PendOutstandingTransaction, autoStart, RFA13},
+		{OrderPending, PendOutstandingTransaction, autoStartMobile, RFA13},
+		{OrderPending, PendNoClient, autoStart, RFA1},
+		{OrderPending, PendStarted, qr, RFA14_A},
+		{OrderPending, PendStarted, qrMobile, RFA14_B},
+		{OrderPending, PendStarted, autoStart, RFA15_A},
+		{OrderPending, PendStarted, autoStartMobile, RFA15_B},
+		{OrderPending, PendUserSign, qr, RFA9},
+		{OrderPending, "userMrtd", qr, RFA21},
+		{OrderFailed, FailExpiredTransaction, qr, RFA8},
+		{OrderFailed, FailCertificateErr, qr, RFA16},
+		{OrderFailed, FailUserCancel, qr, RFA6},
+		{OrderFailed, FailCancelled, qr, RFA3},
+		{OrderFailed, FailStartFailed, autoStart, RFA17_A},
+		{OrderFailed, FailStartFailed, qr, RFA17_B},
+		{OrderFailed, "somethingNew", qr, RFA22},
+		{OrderComplete, "", qr, ""},
+		{"unknown", "", qr, RFA22},
+	} {
+		rsp := &CollectResponse{Status: test.status, HintCode: test.hintCode}
+		assert.Equal(t, test.expected, RFA(rsp, test.ctx), "%s/%s %+v", test.status, test.hintCode, *test.ctx)
+	}
+
+	assert.Equal(t, RFA1, RFA(&CollectResponse{Status: OrderPending, HintCode: PendNoClient}, nil))
+	assert.Equal(t, RFA22, RFA(nil, nil))
+}
+
+func TestRFAForError(t *testing.T) {
+	for err, expected := range map[error]string{
+		nil: "",
+		ErrorResponse{ErrorCode: CodeAlreadyInProgress}:                                    RFA4,
+		fmt.Errorf("could not start order: %w", ErrorResponse{ErrorCode: CodeMaintenance}): RFA5,
+		ErrorResponse{ErrorCode: CodeInternalError}:                                        RFA5,
+		ErrorResponse{ErrorCode: CodeRequestTimeout}:                                       RFA5,
+		&AbortedError{Endpoint: CollectEndpoint, Err: errors.New("timeout")}:               RFA5,
+		&TransportError{StatusCode: 502, Err: errors.New("not JSON")}:                      RFA5,
+		ErrorResponse{ErrorCode: CodeInvalidParameters}:                                    RFA22,
+		errors.New("anything else"):                                                        RFA22,
+	} {
+		assert.Equal(t, expected, RFAForError(err), "%v", err)
+	}
+}
+
+func TestMessagesFor(t *testing.T) {
+	en, _ := NewMessages("en")
+
+	key, text := en.ForCollect(&CollectResponse{Status: OrderFailed, HintCode: FailCertificateErr}, nil)
+	assert.Equal(t, RFA16, key)
+	assert.Equal(t, messages_EN[RFA16], text)
+
+	key, text = en.ForError(ErrorResponse{ErrorCode: CodeAlreadyInProgress})
+	assert.Equal(t, RFA4, key)
+	assert.Equal(t, messages_EN[RFA4], text)
+}
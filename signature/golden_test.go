@@ -0,0 +1,113 @@
+package signature_test
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/onlyangel/bankid/signature"
+)
+
+// golden - what testdata/golden/expected.json says the captured signature contains
+type golden struct {
+	VisibleData    string    `json:"visibleData"`
+	NonVisibleData string    `json:"nonVisibleData"`
+	Nonce          string    `json:"nonce"`
+	RPName         string    `json:"rpName"`
+	RPDisplayName  string    `json:"rpDisplayName"`
+	Function       string    `json:"function"`
+	Subject        string    `json:"subject"`  // serialNumber of the users certificate
+	SignedAt       time.Time `json:"signedAt"` // Within the validity of the chain
+}
+
+// readGolden - the captured signature, the BankID test root and the expected data. See testdata/golden/README.md
+func readGolden(t *testing.T) (string, *x509.CertPool, *golden) {
+	dir := filepath.Join("testdata", "golden")
+	sig, err := os.ReadFile(filepath.Join(dir, "signature.b64"))
+	if errors.Is(err, os.ErrNotExist) {
+		t.Skip("no captured BankID signature in testdata/golden, see its README.md")
+	}
+	if err != nil {
+		t.Fatalf("could not read signature: %s", err.Error())
+	}
+
+	root, err := os.ReadFile(filepath.Join(dir, "root.pem"))
+	if err != nil {
+		t.Fatalf("could not read root: %s", err.Error())
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(root) {
+		t.Fatal("could not parse root.pem")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "expected.json"))
+	if err != nil {
+		t.Fatalf("could not read expected data: %s", err.Error())
+	}
+	expected := &golden{}
+	if err := json.Unmarshal(data, expected); err != nil {
+		t.Fatalf("could not parse expected data: %s", err.Error())
+	}
+	return strings.TrimSpace(string(sig)), roots, expected
+}
+
+func TestVerifyGolden(t *testing.T) {
+	sig, roots, expected := readGolden(t)
+	opts := &signature.Options{Roots: roots, Time: expected.SignedAt}
+
+	verified, err := signature.Verify(sig, opts)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, signature.Data{
+		VisibleData:    []byte(expected.VisibleData),
+		NonVisibleData: []byte(expected.NonVisibleData),
+		Nonce:          expected.Nonce,
+		RPName:         expected.RPName,
+		RPDisplayName:  expected.RPDisplayName,
+		Function:       expected.Function,
+	}, verified.Data)
+	assert.Equal(t, expected.Subject, verified.Certificate.Subject.SerialNumber)
+	assert.Equal(t, 3, len(verified.Chain))
+}
+
+func TestVerifyGoldenTampered(t *testing.T) {
+	sig, roots, expected := readGolden(t)
+	opts := &signature.Options{Roots: roots, Time: expected.SignedAt}
+
+	xml, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil {
+		t.Fatalf("could not decode signature: %s", err.Error())
+	}
+
+	// One byte of the nonce and the visible text, in the signed data, and of the signature value
+	visible := base64.StdEncoding.EncodeToString([]byte(expected.VisibleData))
+	signatureValue := strings.Index(string(xml), "SignatureValue>") + len("SignatureValue>")
+	for name, at := range map[string]int{
+		"nonce":           strings.Index(string(xml), expected.Nonce),
+		"visible data":    strings.Index(string(xml), visible),
+		"signature value": signatureValue + 4,
+	} {
+		if at < 0 {
+			t.Fatalf("%s not found in the signature", name)
+		}
+		tampered := []byte(string(xml))
+		switch tampered[at+1] {
+		case 'A':
+			tampered[at+1] = 'B'
+		default:
+			tampered[at+1] = 'A'
+		}
+
+		_, err := signature.Verify(base64.StdEncoding.EncodeToString(tampered), opts)
+		assert.True(t, errors.Is(err, signature.ErrInvalid), name)
+	}
+}
@@ -0,0 +1,124 @@
+package signature
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// Certificate statuses in the OCSP response
+const (
+	StatusGood    = "good"
+	StatusRevoked = "revoked"
+	StatusUnknown = "unknown"
+)
+
+var (
+	ErrOCSP       = fmt.Errorf("%w: OCSP response", ErrInvalid)
+	ErrOCSPSerial = fmt.Errorf("%w: OCSP response is for another certificate", ErrInvalid)
+)
+
+// OCSP - a verified OCSP response, the status of the users certificate when BankID checked it
+type OCSP struct {
+	Status       string    // StatusGood, StatusRevoked or StatusUnknown
+	SerialNumber *big.Int  // Of the users certificate
+	ProducedAt   time.Time // When the response was signed, the signing time
+	ThisUpdate   time.Time
+	NextUpdate   time.Time         // Zero if the responder doesn't say
+	RevokedAt    time.Time         // Revoked certificates only
+	Responder    *x509.Certificate // Delegated responder, nil if the issuing CA signed the response itself
+	Raw          []byte            // DER, for archiving
+}
+
+// Good - the certificate was neither revoked nor unknown
+func (o *OCSP) Good() bool {
+	return o.Status == StatusGood
+}
+
+// VerifyOCSP - decodes the base64 OCSPResponse from the completion data and verifies that it is
+// signed by the CA that issued the users certificate, or a responder it delegated to, and that
+// it is for the users certificate. The signing time of sig is set to when the response was produced.
+// A revoked certificate is not an error, check the Status
+func (sig *Signature) VerifyOCSP(response string) (*OCSP, error) {
+	der, err := base64.StdEncoding.DecodeString(response)
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not decode base64: %s", ErrOCSP, err.Error())
+	}
+	return sig.VerifyOCSPDER(der)
+}
+
+// VerifyOCSPDER - same as VerifyOCSP() but for the decoded response
+func (sig *Signature) VerifyOCSPDER(der []byte) (*OCSP, error) {
+	if len(sig.Chain) < 2 {
+		return nil, fmt.Errorf("%w: no issuer of the users certificate", ErrOCSP)
+	}
+	issuer := sig.Chain[1]
+
+	// The signature is checked below, ParseResponse only checks an embedded responder certificate against itself
+	rsp, err := ocsp.ParseResponse(der, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not parse: %s", ErrOCSP, err.Error())
+	}
+
+	if rsp.SerialNumber == nil || rsp.SerialNumber.Cmp(sig.Certificate.SerialNumber) != 0 {
+		return nil, fmt.Errorf("%w: serial number %s, certificate %s", ErrOCSPSerial, rsp.SerialNumber, sig.Certificate.SerialNumber)
+	}
+
+	responder := issuer
+	if rsp.Certificate != nil {
+		if err := verifyResponder(rsp.Certificate, issuer, rsp.ProducedAt); err != nil {
+			return nil, err
+		}
+		responder = rsp.Certificate
+	}
+	if err := rsp.CheckSignatureFrom(responder); err != nil {
+		return nil, fmt.Errorf("%w: bad signature: %s", ErrOCSP, err.Error())
+	}
+
+	result := &OCSP{
+		SerialNumber: rsp.SerialNumber,
+		ProducedAt:   rsp.ProducedAt,
+		ThisUpdate:   rsp.ThisUpdate,
+		NextUpdate:   rsp.NextUpdate,
+		RevokedAt:    rsp.RevokedAt,
+		Responder:    rsp.Certificate,
+		Raw:          der,
+	}
+	switch rsp.Status {
+	case ocsp.Good:
+		result.Status = StatusGood
+	case ocsp.Revoked:
+		result.Status = StatusRevoked
+	default:
+		result.Status = StatusUnknown
+	}
+
+	sig.SigningTime = rsp.ProducedAt
+	return result, nil
+}
+
+// verifyResponder - a delegated responder must be issued by the CA, for OCSP signing, and valid when the response was produced
+func verifyResponder(responder *x509.Certificate, issuer *x509.Certificate, at time.Time) error {
+	if err := responder.CheckSignatureFrom(issuer); err != nil {
+		return fmt.Errorf("%w: responder not issued by the users CA: %s", ErrOCSP, err.Error())
+	}
+
+	delegated := false
+	for _, usage := range responder.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageOCSPSigning {
+			delegated = true
+		}
+	}
+	if !delegated {
+		return fmt.Errorf("%w: responder certificate is not for OCSP signing", ErrOCSP)
+	}
+
+	if at.Before(responder.NotBefore) || at.After(responder.NotAfter) {
+		return fmt.Errorf("%w: responder certificate not valid at %s", ErrOCSP, at.Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package signature_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/onlyangel/bankid/signature"
+	"golang.org/x/crypto/ocsp"
+)
+
+// responder - a delegated signature.OCSP responder certificate issued by the bank CA
+func responder(t *testing.T, chain *testChain, usage x509.ExtKeyUsage) (*x509.Certificate, crypto.Signer) {
+	cert, key, err := chain.ca.Responder(usage)
+	if err != nil {
+		t.Fatalf("could not create responder: %s", err.Error())
+	}
+	return cert, key
+}
+
+// ocspResponse - signed by the bank CA, or embedding responderCert when it is a delegated responder
+func ocspResponse(t *testing.T, chain *testChain, status int, serial *big.Int, responderCert *x509.Certificate, key crypto.Signer) string {
+	template := ocsp.Response{
+		Status:       status,
+		SerialNumber: serial,
+		ThisUpdate:   time.Now().Add(-time.Minute),
+		RevokedAt:    time.Now().Add(-time.Hour),
+	}
+	if !responderCert.IsCA {
+		template.Certificate = responderCert
+	}
+	der, err := ocsp.CreateResponse(chain.certs[1], responderCert, template, key)
+	if err != nil {
+		t.Fatalf("could not create OCSP response: %s", err.Error())
+	}
+	return base64.StdEncoding.EncodeToString(der)
+}
+
+func verified(t *testing.T, chain *testChain) *signature.Signature {
+	sig, err := signature.VerifyXML(create(t, chain, testData), &signature.Options{Roots: chain.roots})
+	if err != nil {
+		t.Fatalf("could not verify signature: %s", err.Error())
+	}
+	return sig
+}
+
+func TestVerifyOCSP(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	chain := newTestChain(t, key)
+	sig := verified(t, chain)
+	serial := chain.certs[0].SerialNumber
+
+	// Signed by the bank CA itself
+	result, err := sig.VerifyOCSP(ocspResponse(t, chain, ocsp.Good, serial, chain.certs[1], chain.bankKey))
+	assert.NoError(t, err)
+	assert.True(t, result.Good())
+	assert.Equal(t, signature.StatusGood, result.Status)
+	assert.Equal(t, serial, result.SerialNumber)
+	assert.Nil(t, result.Responder)
+	assert.False(t, result.ProducedAt.IsZero())
+	assert.Equal(t, result.ProducedAt, sig.SigningTime)
+	assert.WithinDuration(t, time.Now().Add(-time.Minute), result.ThisUpdate, time.Second)
+
+	// Signed by a delegated responder
+	responderCert, responderKey := responder(t, chain, x509.ExtKeyUsageOCSPSigning)
+	result, err = sig.VerifyOCSP(ocspResponse(t, chain, ocsp.Revoked, serial, responderCert, responderKey))
+	assert.NoError(t, err)
+	assert.False(t, result.Good())
+	assert.Equal(t, signature.StatusRevoked, result.Status)
+	assert.False(t, result.RevokedAt.IsZero())
+	assert.Equal(t, responderCert, result.Responder)
+
+	result, err = sig.VerifyOCSP(ocspResponse(t, chain, ocsp.Unknown, serial, chain.certs[1], chain.bankKey))
+	assert.NoError(t, err)
+	assert.Equal(t, signature.StatusUnknown, result.Status)
+}
+
+func TestVerifyOCSPInvalid(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	chain := newTestChain(t, key)
+	sig := verified(t, chain)
+	serial := chain.certs[0].SerialNumber
+
+	other := newTestChain(t, key)
+	notForOCSP, notForOCSPKey := responder(t, chain, x509.ExtKeyUsageClientAuth)
+	foreign, foreignKey := responder(t, other, x509.ExtKeyUsageOCSPSigning)
+
+	for name, test := range map[string]struct {
+		response string
+		expected error
+	}{
+		"other serial":          {ocspResponse(t, chain, ocsp.Good, big.NewInt(42), chain.certs[1], chain.bankKey), signature.ErrOCSPSerial},
+		"other CA":              {ocspResponse(t, other, ocsp.Good, serial, other.certs[1], other.bankKey), signature.ErrOCSP},
+		"responder of other CA": {ocspResponse(t, chain, ocsp.Good, serial, foreign, foreignKey), signature.ErrOCSP},
+		"not an OCSP responder": {ocspResponse(t, chain, ocsp.Good, serial, notForOCSP, notForOCSPKey), signature.ErrOCSP},
+		"not base64":            {"not base64!", signature.ErrOCSP},
+		"not OCSP":              {base64.StdEncoding.EncodeToString([]byte("bankidtest")), signature.ErrOCSP},
+	} {
+		_, err := sig.VerifyOCSP(test.response)
+		assert.True(t, errors.Is(err, test.expected), "%s: %v", name, err)
+		assert.True(t, errors.Is(err, signature.ErrInvalid), name)
+	}
+	assert.True(t, sig.SigningTime.IsZero())
+}
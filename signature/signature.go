@@ -0,0 +1,220 @@
+// Package signature - verification of the XML signature in the BankID completion data.
+//
+// BankID returns an enveloping XMLDSig, base64 encoded, with two references:
+// #bidSignedData, what the user identified or signed with, and #bidKeyInfo,
+// the users certificate chain. Verify() checks both digests, the signature value
+// and that the certificate chain leads to the BankID root CA.
+package signature
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/russellhaering/goxmldsig/etreeutils"
+)
+
+// Namespaces
+const (
+	NamespaceDSig   = "http://www.w3.org/2000/09/xmldsig#"
+	NamespaceBankID = "http://www.bankid.com/signature/v1.0.0/types"
+)
+
+// Ids of the signed elements
+const (
+	SignedDataID = "bidSignedData"
+	KeyInfoID    = "bidKeyInfo"
+)
+
+// Function ids, the clientInfo funcId
+const (
+	FunctionIdentification = "Identification"
+	FunctionSigning        = "Signing"
+)
+
+// ErrInvalid - matches every verification error from this package with errors.Is()
+var ErrInvalid = errors.New("invalid signature")
+
+var (
+	ErrMalformed   = fmt.Errorf("%w: malformed", ErrInvalid)
+	ErrDigest      = fmt.Errorf("%w: digest mismatch", ErrInvalid)
+	ErrSignature   = fmt.Errorf("%w: signature value mismatch", ErrInvalid)
+	ErrCertificate = fmt.Errorf("%w: untrusted certificate", ErrInvalid)
+)
+
+// Data - the bankIdSignedData, what the user identified or signed with
+type Data struct {
+	VisibleData    []byte // usrVisibleData, decoded. Empty for most identifications
+	NonVisibleData []byte // usrNonVisibleData, decoded
+	Nonce          string // srvInfo nonce, base64 as in the XML
+	RPName         string // srvInfo name, the RP certificate subject
+	RPDisplayName  string // srvInfo displayName
+	Function       string // clientInfo funcId, FunctionIdentification or FunctionSigning
+}
+
+// Signature - a verified signature
+type Signature struct {
+	Data
+	SigningTime time.Time           // Zero until VerifyOCSP(), the XML signature has no timestamp
+	Certificate *x509.Certificate   // The users certificate
+	Chain       []*x509.Certificate // Verified chain, the users certificate first and the root last
+	XML         []byte              // The decoded signature
+}
+
+// Options - for Verify(), Roots is required
+type Options struct {
+	Roots *x509.CertPool // BankID root CA of the user certificates, test or production. Not the SSL root CA
+	Time  time.Time      // When the certificates must have been valid, default now. Use the signing time for old signatures
+}
+
+// Verify - decodes the base64 signature from the completion data and verifies it
+func Verify(signature string, opts *Options) (*Signature, error) {
+	data, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not decode base64: %s", ErrMalformed, err.Error())
+	}
+	return VerifyXML(data, opts)
+}
+
+// VerifyXML - same as Verify() but for the decoded XML
+func VerifyXML(data []byte, opts *Options) (*Signature, error) {
+	if opts == nil || opts.Roots == nil {
+		return nil, errors.New("could not verify signature: no BankID root certificates")
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(data); err != nil {
+		return nil, fmt.Errorf("%w: could not parse XML: %s", ErrMalformed, err.Error())
+	}
+
+	root := doc.Root()
+	if root == nil || root.Tag != "Signature" || root.NamespaceURI() != NamespaceDSig {
+		return nil, fmt.Errorf("%w: not a ds:Signature", ErrMalformed)
+	}
+
+	ids, err := elementsByID(root)
+	if err != nil {
+		return nil, err
+	}
+
+	signedInfo := child(root, NamespaceDSig, "SignedInfo")
+	signatureValue := child(root, NamespaceDSig, "SignatureValue")
+	if signedInfo == nil || signatureValue == nil {
+		return nil, fmt.Errorf("%w: missing SignedInfo or SignatureValue", ErrMalformed)
+	}
+
+	referenced, err := verifyReferences(signedInfo, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	signedData, keyInfo := referenced[SignedDataID], referenced[KeyInfoID]
+	if signedData == nil || keyInfo == nil {
+		return nil, fmt.Errorf("%w: #%s and #%s must both be signed", ErrMalformed, SignedDataID, KeyInfoID)
+	}
+	if signedData.Tag != "bankIdSignedData" || signedData.NamespaceURI() != NamespaceBankID {
+		return nil, fmt.Errorf("%w: #%s is not a bankIdSignedData", ErrMalformed, SignedDataID)
+	}
+	if keyInfo.Tag != "KeyInfo" || keyInfo.NamespaceURI() != NamespaceDSig || keyInfo.Parent() != root {
+		return nil, fmt.Errorf("%w: #%s is not the signatures KeyInfo", ErrMalformed, KeyInfoID)
+	}
+
+	certs, err := certificates(keyInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifySignatureValue(signedInfo, signatureValue, certs[0]); err != nil {
+		return nil, err
+	}
+
+	chain, err := verifyChain(certs, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	sigData, err := parseData(signedData)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Signature{
+		Data:        *sigData,
+		Certificate: certs[0],
+		Chain:       chain,
+		XML:         data,
+	}, nil
+}
+
+// elementsByID - every element with an Id attribute, duplicates are rejected
+// so a reference can't point at something else than what is read later
+func elementsByID(root *etree.Element) (map[string]*etree.Element, error) {
+	ids := map[string]*etree.Element{}
+	elements := append([]*etree.Element{root}, root.FindElements("//*")...)
+	for _, el := range elements {
+		for _, attr := range el.Attr {
+			if attr.Space != "" || attr.Key != "Id" {
+				continue
+			}
+			if _, ok := ids[attr.Value]; ok {
+				return nil, fmt.Errorf("%w: duplicate Id %q", ErrMalformed, attr.Value)
+			}
+			ids[attr.Value] = el
+		}
+	}
+	return ids, nil
+}
+
+// child - first child element in namespace with the local name tag
+func child(el *etree.Element, namespace string, tag string) *etree.Element {
+	for _, c := range el.ChildElements() {
+		if c.Tag == tag && c.NamespaceURI() == namespace {
+			return c
+		}
+	}
+	return nil
+}
+
+// children - every child element in namespace with the local name tag
+func children(el *etree.Element, namespace string, tag string) []*etree.Element {
+	var found []*etree.Element
+	for _, c := range el.ChildElements() {
+		if c.Tag == tag && c.NamespaceURI() == namespace {
+			found = append(found, c)
+		}
+	}
+	return found
+}
+
+// canonicalize - exclusive XML canonicalization of el, with the namespaces
+// in scope from its ancestors. el itself is not modified
+func canonicalize(el *etree.Element, prefixList string) ([]byte, error) {
+	ctx, err := etreeutils.NSBuildParentContext(el)
+	if err != nil {
+		return nil, err
+	}
+
+	detached, err := etreeutils.NSDetatch(ctx, el)
+	if err != nil {
+		return nil, err
+	}
+
+	return dsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList(prefixList).Canonicalize(detached)
+}
+
+// text - trimmed text of the child, empty if there is none. el may be nil
+func text(el *etree.Element, namespace string, tag string) string {
+	if el == nil {
+		return ""
+	}
+	c := child(el, namespace, tag)
+	if c == nil {
+		return ""
+	}
+	return strings.TrimSpace(c.Text())
+}
@@ -0,0 +1,163 @@
+package signature_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/onlyangel/bankid/internal/signtest"
+	"github.com/onlyangel/bankid/signature"
+)
+
+// testChain - the users certificate chain and key, issued by a signtest.Chain
+type testChain struct {
+	ca      *signtest.Chain
+	roots   *x509.CertPool
+	certs   []*x509.Certificate // User, bank, root
+	userKey crypto.Signer
+	bankKey crypto.Signer
+}
+
+func newTestChain(t *testing.T, userKey crypto.Signer) *testChain {
+	ca, err := signtest.NewChain("Test")
+	if err != nil {
+		t.Fatalf("could not create CA: %s", err.Error())
+	}
+	certs, err := ca.User(signtest.Template("Karl Karlsson", false), userKey)
+	if err != nil {
+		t.Fatalf("could not create certificate: %s", err.Error())
+	}
+	return &testChain{ca: ca, roots: ca.Roots, certs: certs, userKey: userKey, bankKey: ca.BankKey}
+}
+
+var testData = &signature.Data{
+	VisibleData:    []byte("Pay 100 SEK to Kalle"),
+	NonVisibleData: []byte("order=42"),
+	Nonce:          "zVHSUzUTAZKE8KPdahMW2mpXzKY=",
+	RPName:         "cn=FP Testcert 4,name=Test av BankID,serialNumber=5566304928,o=Testbank A AB (publ),c=SE",
+	RPDisplayName:  "Test av BankID",
+	Function:       signature.FunctionSigning,
+}
+
+func create(t *testing.T, chain *testChain, data *signature.Data) []byte {
+	xml, err := signtest.Create(data, chain.certs, chain.userKey)
+	if err != nil {
+		t.Fatalf("could not create signature: %s", err.Error())
+	}
+	return xml
+}
+
+func TestVerifyRSA(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	chain := newTestChain(t, key)
+	xml := create(t, chain, testData)
+
+	sig, err := signature.Verify(base64.StdEncoding.EncodeToString(xml), &signature.Options{Roots: chain.roots})
+	assert.NoError(t, err)
+	assert.Equal(t, *testData, sig.Data)
+	assert.Equal(t, chain.certs[0], sig.Certificate)
+	assert.Equal(t, chain.certs, sig.Chain)
+	assert.Equal(t, xml, sig.XML)
+	assert.True(t, sig.SigningTime.IsZero())
+}
+
+func TestVerifyECDSA(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	chain := newTestChain(t, key)
+
+	// The chain can be in any order, the users certificate is the one that isn't a CA
+	chain.certs = []*x509.Certificate{chain.certs[1], chain.certs[0]}
+	identification := &signature.Data{Nonce: "bm9uY2U=", Function: signature.FunctionIdentification}
+	xml := create(t, chain, identification)
+
+	sig, err := signature.VerifyXML(xml, &signature.Options{Roots: chain.roots})
+	assert.NoError(t, err)
+	assert.Equal(t, signature.FunctionIdentification, sig.Function)
+	assert.Empty(t, sig.VisibleData)
+	assert.Equal(t, "Karl Karlsson", sig.Certificate.Subject.CommonName)
+	assert.Len(t, sig.Chain, 3)
+}
+
+func TestVerifyTampered(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	chain := newTestChain(t, key)
+	xml := string(create(t, chain, testData))
+	opts := &signature.Options{Roots: chain.roots}
+
+	encode := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+
+	for name, test := range map[string]struct {
+		xml      string
+		expected error
+	}{
+		"visible data": {
+			strings.Replace(xml, encode("Pay 100 SEK to Kalle"), encode("Pay 900 SEK to Kalle"), 1),
+			signature.ErrDigest,
+		},
+		"function": {
+			strings.Replace(xml, "<funcId>Signing</funcId>", "<funcId>Identification</funcId>", 1),
+			signature.ErrDigest,
+		},
+		"signed info": {
+			strings.Replace(xml, `Type="`+signature.NamespaceBankID+`"`, `Type="urn:other"`, 1),
+			signature.ErrSignature,
+		},
+		"key info reference dropped": {
+			xml[:strings.LastIndex(xml, "<Reference")] + xml[strings.Index(xml, "</SignedInfo>"):],
+			signature.ErrMalformed,
+		},
+		"wrapped signed data": {
+			strings.Replace(xml, "</Object>", `</Object><Object><bankIdSignedData xmlns="`+signature.NamespaceBankID+`" Id="bidSignedData"/></Object>`, 1),
+			signature.ErrMalformed,
+		},
+		"sha1 digest": {
+			strings.Replace(xml, "http://www.w3.org/2001/04/xmlenc#sha256", "http://www.w3.org/2000/09/xmldsig#sha1", 1),
+			signature.ErrMalformed,
+		},
+		"not xml": {
+			"Signature",
+			signature.ErrMalformed,
+		},
+		"not a signature": {
+			`<Signature xmlns="urn:other"/>`,
+			signature.ErrMalformed,
+		},
+	} {
+		_, err := signature.VerifyXML([]byte(test.xml), opts)
+		assert.True(t, errors.Is(err, test.expected), "%s: %v", name, err)
+		assert.True(t, errors.Is(err, signature.ErrInvalid), name)
+	}
+
+	_, err := signature.Verify("not base64!", opts)
+	assert.True(t, errors.Is(err, signature.ErrMalformed))
+}
+
+func TestVerifyUntrusted(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	chain := newTestChain(t, key)
+	xml := create(t, chain, testData)
+
+	other := newTestChain(t, key)
+	_, err := signature.VerifyXML(xml, &signature.Options{Roots: other.roots})
+	assert.True(t, errors.Is(err, signature.ErrCertificate))
+
+	_, err = signature.VerifyXML(xml, &signature.Options{Roots: chain.roots, Time: time.Now().Add(48 * time.Hour)})
+	assert.True(t, errors.Is(err, signature.ErrCertificate))
+
+	_, err = signature.VerifyXML(xml, &signature.Options{Roots: chain.roots, Time: time.Now().Add(12 * time.Hour)})
+	assert.NoError(t, err)
+
+	_, err = signature.VerifyXML(xml, nil)
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, signature.ErrInvalid))
+}
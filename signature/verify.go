@@ -0,0 +1,290 @@
+package signature
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+
+	// Register the hashes used by the algorithms below
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+const namespaceExcC14N = "http://www.w3.org/2001/10/xml-exc-c14n#"
+
+var excC14N = string(dsig.CanonicalXML10ExclusiveAlgorithmId)
+
+// digestMethods - DigestMethod algorithms, SHA-1 is not accepted
+var digestMethods = map[string]crypto.Hash{
+	"http://www.w3.org/2001/04/xmlenc#sha256":       crypto.SHA256,
+	"http://www.w3.org/2001/04/xmldsig-more#sha384": crypto.SHA384,
+	"http://www.w3.org/2001/04/xmlenc#sha512":       crypto.SHA512,
+}
+
+type signatureMethod struct {
+	hash  crypto.Hash
+	ecdsa bool
+}
+
+// signatureMethods - SignatureMethod algorithms, SHA-1 is not accepted
+var signatureMethods = map[string]signatureMethod{
+	"http://www.w3.org/2001/04/xmldsig-more#rsa-sha256":   {crypto.SHA256, false},
+	"http://www.w3.org/2001/04/xmldsig-more#rsa-sha384":   {crypto.SHA384, false},
+	"http://www.w3.org/2001/04/xmldsig-more#rsa-sha512":   {crypto.SHA512, false},
+	"http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256": {crypto.SHA256, true},
+	"http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha384": {crypto.SHA384, true},
+	"http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha512": {crypto.SHA512, true},
+}
+
+// verifyReferences - checks the digest of every Reference, returns the referenced elements by id
+func verifyReferences(signedInfo *etree.Element, ids map[string]*etree.Element) (map[string]*etree.Element, error) {
+	referenced := map[string]*etree.Element{}
+
+	for _, ref := range children(signedInfo, NamespaceDSig, "Reference") {
+		uri := ref.SelectAttrValue("URI", "")
+		if !strings.HasPrefix(uri, "#") {
+			return nil, fmt.Errorf("%w: unsupported Reference URI %q", ErrMalformed, uri)
+		}
+
+		id := uri[1:]
+		el, ok := ids[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: no element with Id %q", ErrMalformed, id)
+		}
+		if _, ok := referenced[id]; ok {
+			return nil, fmt.Errorf("%w: %s is referenced twice", ErrMalformed, uri)
+		}
+
+		prefixList, err := transforms(ref)
+		if err != nil {
+			return nil, err
+		}
+
+		digestMethod := child(ref, NamespaceDSig, "DigestMethod")
+		if digestMethod == nil {
+			return nil, fmt.Errorf("%w: %s has no DigestMethod", ErrMalformed, uri)
+		}
+		hash, ok := digestMethods[digestMethod.SelectAttrValue("Algorithm", "")]
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported DigestMethod %q", ErrMalformed, digestMethod.SelectAttrValue("Algorithm", ""))
+		}
+
+		expected, err := decodeBase64(text(ref, NamespaceDSig, "DigestValue"))
+		if err != nil {
+			return nil, fmt.Errorf("%w: could not decode DigestValue of %s: %s", ErrMalformed, uri, err.Error())
+		}
+
+		canonical, err := canonicalize(el, prefixList)
+		if err != nil {
+			return nil, fmt.Errorf("%w: could not canonicalize %s: %s", ErrMalformed, uri, err.Error())
+		}
+
+		h := hash.New()
+		h.Write(canonical)
+		if !bytes.Equal(h.Sum(nil), expected) {
+			return nil, fmt.Errorf("%w: %s", ErrDigest, uri)
+		}
+
+		referenced[id] = el
+	}
+
+	return referenced, nil
+}
+
+// transforms - the inclusive namespace prefix list of the exclusive c14n transform,
+// which is the only transform BankID uses
+func transforms(ref *etree.Element) (string, error) {
+	container := child(ref, NamespaceDSig, "Transforms")
+	if container == nil {
+		return "", fmt.Errorf("%w: Reference without exclusive c14n transform", ErrMalformed)
+	}
+
+	transforms := children(container, NamespaceDSig, "Transform")
+	if len(transforms) == 0 {
+		return "", fmt.Errorf("%w: Reference without exclusive c14n transform", ErrMalformed)
+	}
+
+	prefixList := ""
+	for _, transform := range transforms {
+		list, err := c14nPrefixList(transform)
+		if err != nil {
+			return "", err
+		}
+		prefixList = list
+	}
+	return prefixList, nil
+}
+
+// c14nPrefixList - the InclusiveNamespaces PrefixList of an exclusive c14n algorithm element
+func c14nPrefixList(method *etree.Element) (string, error) {
+	algorithm := method.SelectAttrValue("Algorithm", "")
+	if algorithm != excC14N {
+		return "", fmt.Errorf("%w: unsupported algorithm %q, only exclusive c14n", ErrMalformed, algorithm)
+	}
+
+	if inclusive := child(method, namespaceExcC14N, "InclusiveNamespaces"); inclusive != nil {
+		return inclusive.SelectAttrValue("PrefixList", ""), nil
+	}
+	return "", nil
+}
+
+// verifySignatureValue - checks the SignatureValue over the canonical SignedInfo
+func verifySignatureValue(signedInfo *etree.Element, signatureValue *etree.Element, cert *x509.Certificate) error {
+	canonicalizationMethod := child(signedInfo, NamespaceDSig, "CanonicalizationMethod")
+	if canonicalizationMethod == nil {
+		return fmt.Errorf("%w: no CanonicalizationMethod", ErrMalformed)
+	}
+	prefixList, err := c14nPrefixList(canonicalizationMethod)
+	if err != nil {
+		return err
+	}
+
+	signatureMethodEl := child(signedInfo, NamespaceDSig, "SignatureMethod")
+	if signatureMethodEl == nil {
+		return fmt.Errorf("%w: no SignatureMethod", ErrMalformed)
+	}
+	method, ok := signatureMethods[signatureMethodEl.SelectAttrValue("Algorithm", "")]
+	if !ok {
+		return fmt.Errorf("%w: unsupported SignatureMethod %q", ErrMalformed, signatureMethodEl.SelectAttrValue("Algorithm", ""))
+	}
+
+	value, err := decodeBase64(signatureValue.Text())
+	if err != nil {
+		return fmt.Errorf("%w: could not decode SignatureValue: %s", ErrMalformed, err.Error())
+	}
+
+	canonical, err := canonicalize(signedInfo, prefixList)
+	if err != nil {
+		return fmt.Errorf("%w: could not canonicalize SignedInfo: %s", ErrMalformed, err.Error())
+	}
+
+	h := method.hash.New()
+	h.Write(canonical)
+	hashed := h.Sum(nil)
+
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if method.ecdsa {
+			break
+		}
+		if rsa.VerifyPKCS1v15(pub, method.hash, hashed, value) != nil {
+			return ErrSignature
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if !method.ecdsa {
+			break
+		}
+		// XMLDSig uses r || s, not ASN.1
+		if len(value)%2 != 0 {
+			return ErrSignature
+		}
+		r := new(big.Int).SetBytes(value[:len(value)/2])
+		s := new(big.Int).SetBytes(value[len(value)/2:])
+		if !ecdsa.Verify(pub, hashed, r, s) {
+			return ErrSignature
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: the certificates %s key doesn't match the SignatureMethod", ErrMalformed, cert.PublicKeyAlgorithm)
+}
+
+// certificates - the X509Data certificates, the users certificate first
+func certificates(keyInfo *etree.Element) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for _, x509Data := range children(keyInfo, NamespaceDSig, "X509Data") {
+		for _, el := range children(x509Data, NamespaceDSig, "X509Certificate") {
+			der, err := decodeBase64(el.Text())
+			if err != nil {
+				return nil, fmt.Errorf("%w: could not decode X509Certificate: %s", ErrMalformed, err.Error())
+			}
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, fmt.Errorf("%w: could not parse X509Certificate: %s", ErrMalformed, err.Error())
+			}
+			certs = append(certs, cert)
+		}
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%w: no X509Certificate in KeyInfo", ErrMalformed)
+	}
+
+	// The users certificate is the one that isn't a CA
+	for i, cert := range certs {
+		if !cert.IsCA {
+			certs[0], certs[i] = certs[i], certs[0]
+			break
+		}
+	}
+	return certs, nil
+}
+
+// verifyChain - the users certificate must chain up to one of the roots, through the others
+func verifyChain(certs []*x509.Certificate, opts *Options) ([]*x509.Certificate, error) {
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	chains, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         opts.Roots,
+		Intermediates: intermediates,
+		CurrentTime:   opts.Time,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCertificate, err.Error())
+	}
+	return chains[0], nil
+}
+
+// parseData - the fields of a bankIdSignedData element
+func parseData(el *etree.Element) (*Data, error) {
+	data := &Data{
+		Nonce:    text(child(el, NamespaceBankID, "srvInfo"), NamespaceBankID, "nonce"),
+		Function: text(child(el, NamespaceBankID, "clientInfo"), NamespaceBankID, "funcId"),
+	}
+
+	var err error
+	data.VisibleData, err = decodeBase64(text(el, NamespaceBankID, "usrVisibleData"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not decode usrVisibleData: %s", ErrMalformed, err.Error())
+	}
+
+	data.NonVisibleData, err = decodeBase64(text(el, NamespaceBankID, "usrNonVisibleData"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not decode usrNonVisibleData: %s", ErrMalformed, err.Error())
+	}
+
+	srvInfo := child(el, NamespaceBankID, "srvInfo")
+	data.RPName = maybeBase64(text(srvInfo, NamespaceBankID, "name"))
+	data.RPDisplayName = maybeBase64(text(srvInfo, NamespaceBankID, "displayName"))
+	return data, nil
+}
+
+// decodeBase64 - base64 that may be broken over several lines, nil for empty strings
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.Join(strings.Fields(s), "")
+	if s == "" {
+		return nil, nil
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
+
+// maybeBase64 - srvInfo values are base64 encoded, but fall back to the raw value
+func maybeBase64(s string) string {
+	if decoded, err := decodeBase64(s); err == nil {
+		return string(decoded)
+	}
+	return s
+}
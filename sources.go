@@ -0,0 +1,126 @@
+package bankid
+
+// Environments from certificates that don't live on the local file system
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// NewEnvironmentPEM - same as NewEnvironment() but with the PEM encoded certificates in memory
+func NewEnvironmentPEM(baseURL string, caPEM []byte, rpCertPEM []byte, rpKeyPEM []byte) (Environmenter, error) {
+	return New(WithBaseURL(baseURL), WithCA(caPEM), WithKeyPairPEM(rpCertPEM, rpKeyPEM))
+}
+
+// NewEnvironmentP12Data - same as NewEnvironmentP12() but with the certificates in memory
+func NewEnvironmentP12Data(baseURL string, caPEM []byte, p12Data []byte, password string) (Environmenter, error) {
+	return New(WithBaseURL(baseURL), WithCA(caPEM), WithP12(p12Data, password))
+}
+
+// NewEnvironmentFS - same as NewEnvironment() but reads the files from fsys,
+// e.g an embed.FS or os.DirFS of mounted secrets
+func NewEnvironmentFS(baseURL string, fsys fs.FS, caPath string, rpCertPath string, rpKeyPath string) (Environmenter, error) {
+	ca, err := fs.ReadFile(fsys, caPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not load CA Certificate: %s", err.Error())
+	}
+
+	rpCertPEM, err := fs.ReadFile(fsys, rpCertPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not load RP Certificate: %s", err.Error())
+	}
+
+	rpKeyPEM, err := fs.ReadFile(fsys, rpKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not load RP Key: %s", err.Error())
+	}
+
+	return NewEnvironmentPEM(baseURL, ca, rpCertPEM, rpKeyPEM)
+}
+
+// NewEnvironmentP12FS - same as NewEnvironmentP12() but reads the files from fsys
+func NewEnvironmentP12FS(baseURL string, fsys fs.FS, caPath string, rpP12Path string, password string) (Environmenter, error) {
+	ca, err := fs.ReadFile(fsys, caPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not load CA Certificate: %s", err.Error())
+	}
+
+	p12Data, err := fs.ReadFile(fsys, rpP12Path)
+	if err != nil {
+		return nil, fmt.Errorf("could not load p12 certificate: %s", err.Error())
+	}
+
+	return NewEnvironmentP12Data(baseURL, ca, p12Data, password)
+}
+
+// NewEnvironmentFromEnv - same as NewEnvironment() but with the PEM encoded certificates
+// in the named environment variables. The CA variable may also hold the name of an
+// embedded CA certificate, CATest or CAProduction.
+func NewEnvironmentFromEnv(baseURL string, caVar string, rpCertVar string, rpKeyVar string) (Environmenter, error) {
+	ca, err := caFromEnv(caVar)
+	if err != nil {
+		return nil, err
+	}
+
+	rpCertPEM, err := lookupEnv(rpCertVar)
+	if err != nil {
+		return nil, err
+	}
+
+	rpKeyPEM, err := lookupEnv(rpKeyVar)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewEnvironmentPEM(baseURL, ca, []byte(rpCertPEM), []byte(rpKeyPEM))
+}
+
+// NewEnvironmentP12FromEnv - same as NewEnvironmentP12() but with the base64 encoded
+// PKCS#12 file and its password in the named environment variables.
+// The CA variable works as in NewEnvironmentFromEnv().
+func NewEnvironmentP12FromEnv(baseURL string, caVar string, p12Var string, passwordVar string) (Environmenter, error) {
+	ca, err := caFromEnv(caVar)
+	if err != nil {
+		return nil, err
+	}
+
+	p12Base64, err := lookupEnv(p12Var)
+	if err != nil {
+		return nil, err
+	}
+
+	p12Data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(p12Base64))
+	if err != nil {
+		return nil, fmt.Errorf("could not decode p12 certificate in $%s: %s", p12Var, err.Error())
+	}
+
+	password, err := lookupEnv(passwordVar)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewEnvironmentP12Data(baseURL, ca, p12Data, password)
+}
+
+func lookupEnv(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable $%s is not set", name)
+	}
+	return value, nil
+}
+
+func caFromEnv(name string) ([]byte, error) {
+	value, err := lookupEnv(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if ca, err := CACertificate(strings.TrimSpace(value)); err == nil {
+		return ca, nil
+	}
+	return []byte(value), nil
+}
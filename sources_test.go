@@ -0,0 +1,109 @@
+package bankid
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmbeddedCA(t *testing.T) {
+	for name, path := range map[string]string{CATest: "./CA/test.crt", CAProduction: "./CA/production.crt"} {
+		onDisk, err := ioutil.ReadFile(path)
+		assert.Nil(t, err)
+
+		embedded, err := CACertificate(name)
+		assert.Nil(t, err)
+		assert.Equal(t, onDisk, embedded)
+	}
+
+	_, err := CACertificate("staging")
+	assert.NotNil(t, err)
+}
+
+func TestEnvironmentPEM(t *testing.T) {
+	ca, _ := CACertificate(CATest)
+	rpCert, _ := ioutil.ReadFile("./rp/bankid_rp_test.crt")
+	rpKey, _ := ioutil.ReadFile("./rp/bankid_rp_test.key")
+
+	_, err := NewEnvironmentPEM(TestBaseURL, ca, rpCert, rpKey)
+	assert.Nil(t, err)
+
+	_, err = NewEnvironmentPEM(TestBaseURL, ca, rpCert, nil)
+	assert.NotNil(t, err)
+
+	_, err = NewEnvironmentPEM(TestBaseURL, rpKey, rpCert, rpKey)
+	assert.NotNil(t, err)
+}
+
+func TestEnvironmentP12Data(t *testing.T) {
+	ca, _ := CACertificate(CATest)
+	p12Data, _ := ioutil.ReadFile("./rp/test.p12")
+
+	_, err := NewEnvironmentP12Data(TestBaseURL, ca, p12Data, "qwerty123")
+	assert.Nil(t, err)
+
+	_, err = NewEnvironmentP12Data(TestBaseURL, ca, p12Data, "wrong")
+	assert.NotNil(t, err)
+}
+
+func TestEnvironmentFS(t *testing.T) {
+	_, err := NewEnvironmentFS(TestBaseURL, os.DirFS("."), "CA/test.crt", "rp/bankid_rp_test.crt", "rp/bankid_rp_test.key")
+	assert.Nil(t, err)
+
+	_, err = NewEnvironmentP12FS(TestBaseURL, os.DirFS("."), "CA/test.crt", "rp/test.p12", "qwerty123")
+	assert.Nil(t, err)
+
+	// Missing files
+	secrets := fstest.MapFS{
+		"ca.crt": &fstest.MapFile{Data: caTest},
+	}
+	_, err = NewEnvironmentFS(TestBaseURL, secrets, "missing.crt", "rp.crt", "rp.key")
+	assert.NotNil(t, err)
+	_, err = NewEnvironmentFS(TestBaseURL, secrets, "ca.crt", "rp.crt", "rp.key")
+	assert.NotNil(t, err)
+	_, err = NewEnvironmentP12FS(TestBaseURL, secrets, "missing.crt", "rp.p12", "qwerty123")
+	assert.NotNil(t, err)
+	_, err = NewEnvironmentP12FS(TestBaseURL, secrets, "ca.crt", "rp.p12", "qwerty123")
+	assert.NotNil(t, err)
+}
+
+func TestEnvironmentFromEnv(t *testing.T) {
+	rpCert, _ := ioutil.ReadFile("./rp/bankid_rp_test.crt")
+	rpKey, _ := ioutil.ReadFile("./rp/bankid_rp_test.key")
+	p12Data, _ := ioutil.ReadFile("./rp/test.p12")
+
+	// Unset variables
+	_, err := NewEnvironmentFromEnv(TestBaseURL, "BANKID_TEST_CA", "BANKID_TEST_CERT", "BANKID_TEST_KEY")
+	assert.NotNil(t, err)
+
+	t.Setenv("BANKID_TEST_CA", "test")
+	_, err = NewEnvironmentFromEnv(TestBaseURL, "BANKID_TEST_CA", "BANKID_TEST_CERT", "BANKID_TEST_KEY")
+	assert.NotNil(t, err)
+
+	t.Setenv("BANKID_TEST_CERT", string(rpCert))
+	t.Setenv("BANKID_TEST_KEY", string(rpKey))
+	_, err = NewEnvironmentFromEnv(TestBaseURL, "BANKID_TEST_CA", "BANKID_TEST_CERT", "BANKID_TEST_KEY")
+	assert.Nil(t, err)
+
+	// PEM instead of a CA name
+	t.Setenv("BANKID_TEST_CA", string(caTest))
+	_, err = NewEnvironmentFromEnv(TestBaseURL, "BANKID_TEST_CA", "BANKID_TEST_CERT", "BANKID_TEST_KEY")
+	assert.Nil(t, err)
+
+	// PKCS#12
+	_, err = NewEnvironmentP12FromEnv(TestBaseURL, "BANKID_TEST_CA", "BANKID_TEST_P12", "BANKID_TEST_P12_PASSWORD")
+	assert.NotNil(t, err)
+
+	t.Setenv("BANKID_TEST_P12", "not base64")
+	t.Setenv("BANKID_TEST_P12_PASSWORD", "qwerty123")
+	_, err = NewEnvironmentP12FromEnv(TestBaseURL, "BANKID_TEST_CA", "BANKID_TEST_P12", "BANKID_TEST_P12_PASSWORD")
+	assert.NotNil(t, err)
+
+	t.Setenv("BANKID_TEST_P12", base64.StdEncoding.EncodeToString(p12Data)+"\n")
+	_, err = NewEnvironmentP12FromEnv(TestBaseURL, "BANKID_TEST_CA", "BANKID_TEST_P12", "BANKID_TEST_P12_PASSWORD")
+	assert.Nil(t, err)
+}
@@ -0,0 +1,96 @@
+package bankid
+
+// Facts derived from the personal number of a signed in user
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/onlyangel/bankid/personnummer"
+)
+
+// ErrUnderage - matches every AgeError, use with errors.Is()
+var ErrUnderage = errors.New("user is too young")
+
+// AgeError - the user is younger than required
+type AgeError struct {
+	Age    int
+	MinAge int
+}
+
+// Error -
+func (e *AgeError) Error() string {
+	return fmt.Sprintf("user is %d, must be at least %d", e.Age, e.MinAge)
+}
+
+// Is - makes errors.Is(err, ErrUnderage) work
+func (e *AgeError) Is(target error) bool {
+	return target == ErrUnderage
+}
+
+// Number - the parsed personal number
+func (u User) Number() (personnummer.Number, error) {
+	n, err := personnummer.Parse(u.PersonalNumber)
+	if err != nil {
+		return personnummer.Number{}, fmt.Errorf("could not parse personal number: %w", err)
+	}
+	return n, nil
+}
+
+// BirthDate - midnight UTC on the users day of birth
+func (u User) BirthDate() (time.Time, error) {
+	n, err := u.Number()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return n.BirthDate(), nil
+}
+
+// AgeAt - the users age in whole years on the date of at
+func (u User) AgeAt(at time.Time) (int, error) {
+	n, err := u.Number()
+	if err != nil {
+		return 0, err
+	}
+	return n.AgeAt(at), nil
+}
+
+// Gender - the users legal gender
+func (u User) Gender() (personnummer.Gender, error) {
+	n, err := u.Number()
+	if err != nil {
+		return "", err
+	}
+	return n.Gender(), nil
+}
+
+// IsCoordinationNumber - true if the user signed in with a samordningsnummer
+func (u User) IsCoordinationNumber() (bool, error) {
+	n, err := u.Number()
+	if err != nil {
+		return false, err
+	}
+	return n.Coordination, nil
+}
+
+// RequireAge - age gate, nil if the user is at least minAge years old at the time at,
+// an *AgeError if not. Use the Swedish date for at, e.g time.Now().In(loc) with Europe/Stockholm
+func (u User) RequireAge(minAge int, at time.Time) error {
+	age, err := u.AgeAt(at)
+	if err != nil {
+		return err
+	}
+	if age < minAge {
+		return &AgeError{Age: age, MinAge: minAge}
+	}
+	return nil
+}
+
+// RequireAge - same as User.RequireAge() for a collect response, errors unless the order is complete
+func RequireAge(rsp *CollectResponse, minAge int, at time.Time) error {
+	if rsp == nil || rsp.Status != OrderComplete || rsp.CompletionData == nil {
+		return errors.New("order is not complete")
+	}
+	return rsp.CompletionData.User.RequireAge(minAge, at)
+}
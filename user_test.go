@@ -0,0 +1,58 @@
+package bankid
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/onlyangel/bankid/personnummer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserFacts(t *testing.T) {
+	user := User{PersonalNumber: "197010632391", Name: "Karl Karlsson"}
+
+	birth, err := user.BirthDate()
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(1970, 10, 3, 0, 0, 0, 0, time.UTC), birth)
+
+	age, err := user.AgeAt(time.Date(2026, 10, 2, 12, 0, 0, 0, time.UTC))
+	assert.Nil(t, err)
+	assert.Equal(t, 55, age)
+
+	gender, err := user.Gender()
+	assert.Nil(t, err)
+	assert.Equal(t, personnummer.Male, gender)
+
+	coordination, err := user.IsCoordinationNumber()
+	assert.Nil(t, err)
+	assert.True(t, coordination)
+
+	_, err = User{PersonalNumber: "190000000000"}.BirthDate()
+	assert.True(t, errors.Is(err, personnummer.ErrInvalid))
+	_, err = User{}.Gender()
+	assert.True(t, errors.Is(err, personnummer.ErrFormat))
+}
+
+func TestRequireAge(t *testing.T) {
+	user := User{PersonalNumber: "200810180000"}
+	birthday := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+
+	assert.Nil(t, user.RequireAge(18, birthday))
+
+	err := user.RequireAge(18, birthday.Add(-time.Minute))
+	assert.True(t, errors.Is(err, ErrUnderage))
+	var ageErr *AgeError
+	if assert.True(t, errors.As(err, &ageErr)) {
+		assert.Equal(t, 17, ageErr.Age)
+		assert.Equal(t, 18, ageErr.MinAge)
+	}
+
+	rsp := &CollectResponse{Status: OrderComplete, CompletionData: &Completion{User: user}}
+	assert.Nil(t, RequireAge(rsp, 18, birthday))
+	assert.True(t, errors.Is(RequireAge(rsp, 20, birthday), ErrUnderage))
+
+	assert.NotNil(t, RequireAge(&CollectResponse{Status: OrderPending}, 18, birthday))
+	assert.NotNil(t, RequireAge(nil, 18, birthday))
+	assert.True(t, errors.Is(RequireAge(&CollectResponse{Status: OrderComplete, CompletionData: &Completion{}}, 18, birthday), personnummer.ErrInvalid))
+}
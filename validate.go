@@ -0,0 +1,148 @@
+package bankid
+
+// Client side checks of the documented request limits and formats
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/onlyangel/bankid/personnummer"
+)
+
+// Documented limits, in characters after base64 encoding
+const (
+	MaxUserVisibleDataLength    = 40000
+	MaxUserNonVisibleDataLength = 200000
+)
+
+// Card reader classes, see Requirement.CardReader
+const (
+	CardReaderClass1 = "class1"
+	CardReaderClass2 = "class2"
+)
+
+var certificatePolicy = regexp.MustCompile(`^[0-9]+(\.[0-9]+)+$`)
+
+// FieldError - a request field BankID would reject with invalidParameters, found before calling BankID.
+// Matches ErrInvalidParameters with errors.Is() and unwraps to the cause, e.g a personnummer error
+type FieldError struct {
+	Field  string // JSON name, e.g "userVisibleData" or "requirement.personalNumber"
+	Size   int    // Measured size for the length limits, characters after base64 encoding for the user data
+	Limit  int    // The limit Size is over
+	Reason string
+	Err    error
+}
+
+// Error -
+func (e *FieldError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("invalid %s: %s", e.Field, e.Err.Error())
+	}
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+// Is - makes errors.Is(err, ErrInvalidParameters) work
+func (e *FieldError) Is(target error) bool {
+	return target == ErrInvalidParameters
+}
+
+// Unwrap - the cause, if any
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// validateRequest - every documented limit and format BankID checks that can be checked
+// locally, so oversized or malformed requests never reach the network
+func validateRequest(endpoint string, request *Request) error {
+	if request.EndUserIP == "" {
+		return &FieldError{Field: "endUserIp", Reason: "required"}
+	}
+	if net.ParseIP(request.EndUserIP) == nil {
+		return &FieldError{Field: "endUserIp", Reason: fmt.Sprintf("%q is not an IPv4 or IPv6 address", request.EndUserIP)}
+	}
+
+	if endpoint == SignEndpoint && request.UserVisibleData == "" {
+		return &FieldError{Field: "userVisibleData", Reason: "required to sign"}
+	}
+	if !utf8.ValidString(request.UserVisibleData) {
+		return &FieldError{Field: "userVisibleData", Reason: "not UTF-8"}
+	}
+	if err := maxEncodedLength("userVisibleData", request.UserVisibleData, MaxUserVisibleDataLength); err != nil {
+		return err
+	}
+	if err := maxEncodedLength("userNonVisibleData", request.UserNonVisibleData, MaxUserNonVisibleDataLength); err != nil {
+		return err
+	}
+
+	switch request.UserVisibleDataFormat {
+	case "", FormatPlaintext, FormatSimpleMarkdown:
+	default:
+		return &FieldError{Field: "userVisibleDataFormat", Reason: fmt.Sprintf("%q is not %s or %s", request.UserVisibleDataFormat, FormatPlaintext, FormatSimpleMarkdown)}
+	}
+
+	if request.ReturnURL != "" {
+		if u, err := url.Parse(request.ReturnURL); err != nil || !u.IsAbs() {
+			return &FieldError{Field: "returnUrl", Reason: fmt.Sprintf("%q is not an absolute URL", request.ReturnURL)}
+		}
+	}
+
+	switch request.CallInitiator {
+	case "", CallInitiatorUser, CallInitiatorRP:
+	default:
+		return &FieldError{Field: "callInitiator", Reason: fmt.Sprintf("%q is not %s or %s", request.CallInitiator, CallInitiatorUser, CallInitiatorRP)}
+	}
+
+	if r := request.Requirement; r != nil {
+		switch r.CardReader {
+		case "", CardReaderClass1, CardReaderClass2:
+		default:
+			return &FieldError{Field: "requirement.cardReader", Reason: fmt.Sprintf("%q is not %s or %s", r.CardReader, CardReaderClass1, CardReaderClass2)}
+		}
+		for _, policy := range r.CertificatePolicies {
+			if !certificatePolicy.MatchString(policy) {
+				return &FieldError{Field: "requirement.certificatePolicies", Reason: fmt.Sprintf("%q is not an OID", policy)}
+			}
+		}
+	}
+	return nil
+}
+
+// maxEncodedLength - data must be at most limit characters after base64 encoding
+func maxEncodedLength(field string, data string, limit int) error {
+	size := base64.StdEncoding.EncodedLen(len(data))
+	if size > limit {
+		return &FieldError{
+			Field:  field,
+			Size:   size,
+			Limit:  limit,
+			Reason: fmt.Sprintf("%d characters after base64 encoding, at most %d", size, limit),
+		}
+	}
+	return nil
+}
+
+// normalizePersonalNumber - validate the personal numbers, if any, and put them
+// in the YYYYMMDDNNNN form BankID wants. Invalid numbers never reach the network
+func normalizePersonalNumber(request Request) (Request, error) {
+	var err error
+	if request.PersonalNumber != "" {
+		request.PersonalNumber, err = personnummer.Normalize(request.PersonalNumber)
+		if err != nil {
+			return request, &FieldError{Field: "personalNumber", Err: err}
+		}
+	}
+
+	if request.Requirement != nil && request.Requirement.PersonalNumber != "" {
+		requirement := *request.Requirement
+		requirement.PersonalNumber, err = personnummer.Normalize(requirement.PersonalNumber)
+		if err != nil {
+			return request, &FieldError{Field: "requirement.personalNumber", Err: err}
+		}
+		request.Requirement = &requirement
+	}
+	return request, nil
+}
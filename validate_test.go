@@ -0,0 +1,81 @@
+package bankid
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/onlyangel/bankid/personnummer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRequest(t *testing.T) {
+	valid := Request{EndUserIP: "127.0.0.1", UserVisibleData: "Hi User"}
+	assert.Nil(t, validateRequest(SignEndpoint, &valid))
+	assert.Nil(t, validateRequest(AuthEndpoint, &Request{EndUserIP: "2001:db8::1"}))
+
+	// 30000 bytes is exactly 40000 characters after base64 encoding
+	atLimit := valid
+	atLimit.UserVisibleData = strings.Repeat("a", 30000)
+	atLimit.UserNonVisibleData = strings.Repeat("a", 150000)
+	assert.Nil(t, validateRequest(SignEndpoint, &atLimit))
+
+	for field, test := range map[string]struct {
+		endpoint string
+		change   func(r *Request)
+		size     int
+	}{
+		"endUserIp":                       {AuthEndpoint, func(r *Request) { r.EndUserIP = "" }, 0},
+		"userVisibleData":                 {SignEndpoint, func(r *Request) { r.UserVisibleData = strings.Repeat("å", 15001) }, 40004},
+		"userNonVisibleData":              {AuthEndpoint, func(r *Request) { r.UserNonVisibleData = strings.Repeat("a", 150001) }, 200004},
+		"userVisibleDataFormat":           {SignEndpoint, func(r *Request) { r.UserVisibleDataFormat = "markdown" }, 0},
+		"returnUrl":                       {AuthEndpoint, func(r *Request) { r.ReturnURL = "/return" }, 0},
+		"callInitiator":                   {AuthEndpoint, func(r *Request) { r.CallInitiator = "bank" }, 0},
+		"requirement.cardReader":          {AuthEndpoint, func(r *Request) { r.Requirement = &Requirement{CardReader: "class3"} }, 0},
+		"requirement.certificatePolicies": {AuthEndpoint, func(r *Request) { r.Requirement = &Requirement{CertificatePolicies: []string{"1.2.752.78.*"}} }, 0},
+	} {
+		request := valid
+		test.change(&request)
+		err := validateRequest(test.endpoint, &request)
+
+		var fieldErr *FieldError
+		assert.True(t, errors.As(err, &fieldErr), field)
+		assert.Equal(t, field, fieldErr.Field)
+		assert.Equal(t, test.size, fieldErr.Size, field)
+		assert.True(t, errors.Is(err, ErrInvalidParameters), field)
+	}
+
+	err := validateRequest(AuthEndpoint, &Request{EndUserIP: "not an ip"})
+	assert.Equal(t, `invalid endUserIp: "not an ip" is not an IPv4 or IPv6 address`, err.Error())
+
+	err = validateRequest(SignEndpoint, &Request{EndUserIP: "127.0.0.1", UserVisibleData: "\xff"})
+	assert.Equal(t, "invalid userVisibleData: not UTF-8", err.Error())
+}
+
+func TestFieldErrorBeforeCall(t *testing.T) {
+	calls := 0
+	env := &versionedTestEnv{version: APIVersionV6}
+	env.handler = func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		json.NewEncoder(w).Encode(&Response{OrderRef: "131daac9-16c6-4618-beb0-365768f37288"})
+	}
+
+	_, err := Sign(env, "198001010001", "127.0.0.1", strings.Repeat("a", 30001), "")
+	var fieldErr *FieldError
+	assert.True(t, errors.As(err, &fieldErr))
+	assert.Equal(t, "userVisibleData", fieldErr.Field)
+	assert.Equal(t, 40004, fieldErr.Size)
+	assert.Equal(t, MaxUserVisibleDataLength, fieldErr.Limit)
+	assert.Equal(t, "could not start order: invalid userVisibleData: 40004 characters after base64 encoding, at most 40000", err.Error())
+
+	_, err = Auth(env, "198001010000", "127.0.0.1")
+	assert.True(t, errors.As(err, &fieldErr))
+	assert.Equal(t, "personalNumber", fieldErr.Field)
+	assert.True(t, errors.Is(err, personnummer.ErrChecksum))
+
+	_, err = Auth(env, "198001010001", "")
+	assert.True(t, errors.Is(err, ErrInvalidParameters))
+	assert.Equal(t, 0, calls)
+}